@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/ui"
+)
+
+// loadSettings 读取用户配置与项目 .gentr.json，合并为生效设置
+// 返回用户配置文件的路径 (不存在时为空)，错误信息中包含出错的文件
+func loadSettings(absPath string) (core.ResolvedSettings, string, error) {
+	user, userPath, err := core.LoadUserConfig()
+	if err != nil {
+		return core.ResolvedSettings{}, userPath, fmt.Errorf("Error in %s: %v", userPath, err)
+	}
+	project, err := core.LoadProjectSettings(absPath)
+	if err != nil {
+		return core.ResolvedSettings{}, userPath, fmt.Errorf("Error in %s: %v", filepath.Join(absPath, core.ConfigFileName), err)
+	}
+	return core.ResolveSettings(user, project), userPath, nil
+}
+
+// loadKeyMap 根据生效设置中的按键绑定生成 KeyMap，并检查自定义命令的按键
+// 错误信息中包含按键绑定或命令的来源
+func loadKeyMap(settings core.ResolvedSettings) (ui.KeyMap, error) {
+	keys, err := ui.LoadKeyMap(settings.Keys)
+	if err != nil {
+		return keys, fmt.Errorf("Error in key bindings (%s): %v", strings.Join(settings.KeySources(), ", "), err)
+	}
+	if err := keys.ValidateCommands(settings.Commands); err != nil {
+		return keys, fmt.Errorf("Error in commands (%s): %v", settings.Sources["commands"], err)
+	}
+	return keys, nil
+}
+
+// limitFlags 是扫描限制相关的命令行参数，TUI、export 与 config 共用
+type limitFlags struct {
+	maxFiles    int
+	maxDepth    int
+	noGitignore bool
+}
+
+// limitUsage 是 limitFlags 的帮助信息
+const limitUsage = `      --max-files <n>   Stop after n files and folders (default: 5000)
+      --max-depth <n>   Only descend n levels deep (default: 10)
+      --no-gitignore    Show files ignored by .gitignore (keeps configured ignore rules)
+`
+
+// register 把参数注册到 FlagSet
+func (f *limitFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.maxFiles, "max-files", 0, "Maximum number of files")
+	fs.IntVar(&f.maxDepth, "max-depth", 0, "Maximum depth")
+	fs.BoolVar(&f.noGitignore, "no-gitignore", false, "Ignore .gitignore")
+}
+
+// apply 用显式给出的参数覆盖设置 (优先级最高)
+func (f *limitFlags) apply(fs *flag.FlagSet, settings *core.ResolvedSettings) error {
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "max-files":
+			if f.maxFiles < 1 {
+				err = fmt.Errorf("--max-files must be at least 1")
+			}
+			settings.SetMaxFiles(f.maxFiles, "--max-files")
+		case "max-depth":
+			if f.maxDepth < 1 {
+				err = fmt.Errorf("--max-depth must be at least 1")
+			}
+			settings.SetMaxDepth(f.maxDepth, "--max-depth")
+		case "no-gitignore":
+			if f.noGitignore {
+				settings.SetNoGitignore("--no-gitignore")
+			}
+		}
+	})
+	return err
+}
+
+// runConfig 实现 `gentr config` 子命令：打印合并后的生效设置及每一项的来源
+// 返回值为进程退出码
+func runConfig(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+
+	var (
+		pathFlag  string
+		forceMode bool
+		lazyMode  bool
+		limits    limitFlags
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Print the effective settings and where each value comes from.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n  gentr config [flags] [path]\n\n")
+		fmt.Fprintf(os.Stderr, "Precedence: flags > %s > user config > defaults\n\n", core.ConfigFileName)
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fmt.Fprintf(os.Stderr, "  -p, --path <dir>      Project directory (default: current directory)\n")
+		fmt.Fprintf(os.Stderr, "  -f, --force           Show the settings used in force mode\n")
+		fmt.Fprint(os.Stderr, limitUsage)
+		fmt.Fprintf(os.Stderr, "      --lazy            Show the settings used in lazy mode\n")
+	}
+
+	fs.StringVar(&pathFlag, "p", "", "Target directory path")
+	fs.StringVar(&pathFlag, "path", "", "Target directory path")
+	fs.BoolVar(&forceMode, "f", false, "Force mode")
+	fs.BoolVar(&forceMode, "force", false, "Force mode")
+	fs.BoolVar(&lazyMode, "lazy", false, "Lazy mode")
+	limits.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	targetPath := "."
+	if pathFlag != "" {
+		targetPath = pathFlag
+	} else if fs.NArg() > 0 {
+		targetPath = fs.Arg(0)
+	}
+
+	absPath, err := resolveTargetPath(targetPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	settings, userPath, err := loadSettings(absPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if forceMode {
+		settings.ApplyForce("--force")
+	}
+	if err := limits.apply(fs, &settings); err != nil {
+		fmt.Fprintf(os.Stderr, "[Error] %v\n", err)
+		return 2
+	}
+	if lazyMode {
+		settings.SetLazy("--lazy")
+	}
+
+	// 配置文件的位置
+	if userPath == "" {
+		dir, err := core.UserConfigDir()
+		if err != nil {
+			userPath = "(no user config directory)"
+		} else {
+			userPath = fmt.Sprintf("(none, looked for %s in %s)", strings.Join(core.UserConfigFileNames, " or "), dir)
+		}
+	}
+	projectPath := filepath.Join(absPath, core.ConfigFileName)
+	if _, err := os.Stat(projectPath); err != nil {
+		projectPath = "(none)"
+	}
+	fmt.Printf("User config:    %s\n", userPath)
+	fmt.Printf("Project config: %s\n\n", projectPath)
+
+	// 每一项设置：名称、取值、来源
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	row := func(name, value string) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, value, settings.Sources[name])
+	}
+	row("max_files", limitValue(settings.MaxFiles))
+	row("max_depth", limitValue(settings.MaxDepth))
+	row("no_gitignore", jsonValue(settings.NoGitignore))
+	row("lazy", jsonValue(settings.Lazy))
+	row("ignore", jsonValue(settings.Ignore))
+	if settings.Theme == "" {
+		row("theme", "(dark & light)")
+	} else {
+		row("theme", jsonValue(settings.Theme))
+	}
+	if settings.ExportDir == "" {
+		row("export_dir", "(current directory)")
+	} else {
+		row("export_dir", jsonValue(settings.ExportDir))
+	}
+	row("update_check", jsonValue(settings.UpdateCheck))
+
+	// 按键绑定只列出被修改的动作
+	var actions []string
+	for action := range settings.Keys {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		row("keys."+action, jsonValue(settings.Keys[action]))
+	}
+	if len(actions) == 0 {
+		fmt.Fprintf(w, "keys\t(defaults)\t%s\n", core.SourceDefault)
+	}
+
+	// 自定义命令：按键 (没有按键时为名称) 与命令内容
+	for _, c := range settings.Commands {
+		label := c.Key
+		if label == "" {
+			label = c.Title()
+		}
+		fmt.Fprintf(w, "commands.%s\t%s\t%s\n", label, jsonValue(c.Run), c.Source)
+	}
+	w.Flush()
+
+	// 与启动 TUI 时一样检查按键冲突 (包括自定义命令的按键)
+	if _, err := loadKeyMap(settings); err != nil {
+		fmt.Fprintf(os.Stderr, "\n%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// limitValue 格式化扫描限制，Force 模式下为 unlimited
+func limitValue(n int) string {
+	if n >= math.MaxInt32 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
+
+// jsonValue 以 JSON 的形式显示取值，与配置文件中的写法一致
+func jsonValue(v any) string {
+	if list, ok := v.([]string); ok && list == nil {
+		v = []string{}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
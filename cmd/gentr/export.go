@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/export"
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// runExport 实现 `gentr export` 子命令：扫描目录并直接输出结果，不需要 TTY
+// 返回值为进程退出码
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	var (
+		pathFlag   string
+		formatFlag string
+		outputFlag string
+		themeFlag  string
+		gitFlag    bool
+		allFlag    bool
+		diffFlag   string
+		queryFlag  string
+		limits     limitFlags
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Render the project tree without launching the TUI.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n  gentr export [flags] [path]\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fmt.Fprintf(os.Stderr, "  -p, --path <dir>      Target directory path (default: current directory)\n")
+		fmt.Fprintf(os.Stderr, "      --format <fmt>    Output format: text, md, svg, json (default: text)\n")
+		fmt.Fprintf(os.Stderr, "  -o, --output <file>   Write to file instead of stdout\n")
+		fmt.Fprintf(os.Stderr, "      --theme <name>    SVG theme: dark, light (default: theme setting or dark)\n")
+		fmt.Fprintf(os.Stderr, "      --git             Only show changed files and append git markers\n")
+		fmt.Fprintf(os.Stderr, "      --diff <ref>      Compare against a git ref (implies --git)\n")
+		fmt.Fprintf(os.Stderr, "  -q, --query <query>   Only export nodes matching a search query (same syntax as /)\n")
+		fmt.Fprintf(os.Stderr, "      --all             JSON only: include hidden and collapsed nodes\n")
+		fmt.Fprint(os.Stderr, limitUsage)
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  gentr export\n")
+		fmt.Fprintf(os.Stderr, "  gentr export --format md -o TREE.md\n")
+		fmt.Fprintf(os.Stderr, "  gentr export --format svg --theme light -o tree.svg src/\n")
+		fmt.Fprintf(os.Stderr, "  gentr export --format json --all -o tree.json\n")
+		fmt.Fprintf(os.Stderr, "  gentr export --format md --diff main...HEAD\n")
+		fmt.Fprintf(os.Stderr, "  gentr export -q 'ext:go -name:*_test.go'\n")
+	}
+
+	fs.StringVar(&pathFlag, "p", "", "Target directory path")
+	fs.StringVar(&pathFlag, "path", "", "Target directory path")
+	fs.StringVar(&formatFlag, "format", "text", "Output format")
+	fs.StringVar(&outputFlag, "o", "", "Output file")
+	fs.StringVar(&outputFlag, "output", "", "Output file")
+	fs.StringVar(&themeFlag, "theme", "", "SVG theme")
+	fs.BoolVar(&gitFlag, "git", false, "Git changes only")
+	fs.BoolVar(&allFlag, "all", false, "Include hidden and collapsed nodes (JSON)")
+	fs.StringVar(&diffFlag, "diff", "", "Compare against git ref")
+	fs.StringVar(&queryFlag, "q", "", "Search query")
+	fs.StringVar(&queryFlag, "query", "", "Search query")
+	limits.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	// 扫描前先检查格式与主题，避免白白扫描一遍
+	format := strings.ToLower(formatFlag)
+	switch format {
+	case "text", "txt", "md", "markdown", "svg", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "[Error] Unknown format '%s' (expected text, md, svg or json).\n", formatFlag)
+		return 2
+	}
+	if _, ok := export.ThemeByName(themeFlag); themeFlag != "" && !ok {
+		fmt.Fprintf(os.Stderr, "[Error] Unknown theme '%s' (expected dark or light).\n", themeFlag)
+		return 2
+	}
+
+	// 解析目标路径 (优先级: -p > 位置参数 > 当前目录)
+	targetPath := "."
+	if pathFlag != "" {
+		targetPath = pathFlag
+	} else if fs.NArg() > 0 {
+		targetPath = fs.Arg(0)
+	}
+
+	absPath, err := resolveTargetPath(targetPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// 查询语法同样在扫描前检查
+	query, err := core.ParseQuery(queryFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Error] Invalid query: %v\n", err)
+		return 2
+	}
+
+	// 用户配置与 .gentr.json 中的扫描限制、忽略规则与主题
+	settings, _, err := loadSettings(absPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if themeFlag != "" {
+		settings.SetTheme(themeFlag, "--theme")
+	}
+	if err := limits.apply(fs, &settings); err != nil {
+		fmt.Fprintf(os.Stderr, "[Error] %v\n", err)
+		return 2
+	}
+
+	// 扫描文件并应用持久化配置 (隐藏/折叠/注释)
+	// Ctrl+C 时取消扫描
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	walkOpts := settings.WalkOptions()
+	walkOpts.DiffRef = diffFlag
+	walkOpts.Lazy = false // 导出需要完整的树，lazy 设置只对 TUI 生效
+
+	rootNode, limitInfo, err := core.Walk(ctx, absPath, walkOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning directory: %v\n", err)
+		return 1
+	}
+	_ = core.LoadConfig(absPath, rootNode)
+
+	if limitInfo.Reached() {
+		fmt.Fprintf(os.Stderr, "[!] Safety Limit Reached: %s. Raise it with --max-files / --max-depth.\n", limitInfo)
+	}
+
+	// 对比模式下导出的就是分支的变更集，因此默认开启 Git 过滤
+	opts := export.Options{GitMode: gitFlag || diffFlag != ""}
+
+	// 与 TUI 搜索一致：显示满足条件的节点及其祖先，并展开所有文件夹
+	if query != nil {
+		visible := query.Visible(absPath, rootNode)
+		opts.Filter = func(node *model.Node) bool { return visible[node] }
+		opts.Expand = true
+	}
+
+	var content string
+	switch format {
+	case "text", "txt":
+		content = export.Text(rootNode, opts)
+	case "md", "markdown":
+		content = export.Markdown(rootNode, opts) + "\n"
+	case "svg":
+		themeName := settings.Theme
+		if themeName == "" {
+			themeName = "dark"
+		}
+		// --theme 已在开头检查，配置中的主题在读取设置时检查
+		theme, _ := export.ThemeByName(themeName)
+		content = export.SVG(rootNode, theme, opts)
+	case "json":
+		data, err := export.JSON(rootNode, opts, allFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+		content = string(data) + "\n"
+	}
+
+	// 未指定输出文件时写到标准输出，便于管道使用
+	if outputFlag == "" {
+		fmt.Print(content)
+		return 0
+	}
+
+	if err := os.WriteFile(outputFlag, []byte(content), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving file: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Saved to %s\n", outputFlag)
+	return 0
+}
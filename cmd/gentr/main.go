@@ -17,26 +17,47 @@ import (
 const Version = "1.0.2"
 
 func main() {
+	// 子命令分发：export 为无界面模式，不启动 TUI
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+	// config 打印合并后的设置
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+
 	// 定义命令行参数 Flags
 	var (
 		pathFlag    string
 		showVersion bool
 		forceMode   bool
+		diffRef     string
+		lazyMode    bool
+		limits      limitFlags
 	)
 
 	// 自定义帮助信息 (-h / --help)
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Gentr - A smart project tree generator CLI tool.\n\n")
-		fmt.Fprintf(os.Stderr, "Usage:\n  gentr [flags] [path]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n  gentr [flags] [path]\n  gentr export [flags] [path]\n  gentr config [flags] [path]\n\n")
+		fmt.Fprintf(os.Stderr, "To open a folder named export or config, write it as a path: gentr ./export\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
-		fmt.Fprintf(os.Stderr, "  -p, --path <dir>   Target directory path (default: current directory)\n")
-		fmt.Fprintf(os.Stderr, "  -f, --force        Force mode: Ignore .gitignore and file limits (Dangerous!)\n")
-		fmt.Fprintf(os.Stderr, "  -v, --version      Show version information\n")
-		fmt.Fprintf(os.Stderr, "  -h, --help         Show this help message\n")
+		fmt.Fprintf(os.Stderr, "  -p, --path <dir>      Target directory path (default: current directory)\n")
+		fmt.Fprintf(os.Stderr, "  -f, --force           Force mode: Ignore .gitignore and file limits (Dangerous!)\n")
+		fmt.Fprint(os.Stderr, limitUsage)
+		fmt.Fprintf(os.Stderr, "      --lazy            Read folders only when they are expanded (for huge trees)\n")
+		fmt.Fprintf(os.Stderr, "      --diff <ref>      Show changes against a git ref (e.g. main, main...HEAD)\n")
+		fmt.Fprintf(os.Stderr, "  -v, --version         Show version information\n")
+		fmt.Fprintf(os.Stderr, "  -h, --help            Show this help message\n")
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  gentr\n")
 		fmt.Fprintf(os.Stderr, "  gentr src/\n")
 		fmt.Fprintf(os.Stderr, "  gentr -p ../other-project\n")
+		fmt.Fprintf(os.Stderr, "  gentr --diff main...HEAD\n")
+		fmt.Fprintf(os.Stderr, "  gentr --max-files 20000 --max-depth 20\n")
+		fmt.Fprintf(os.Stderr, "  gentr --lazy --no-gitignore ~\n")
+		fmt.Fprintf(os.Stderr, "  gentr export --format md -o TREE.md\n")
+		fmt.Fprintf(os.Stderr, "  gentr config\n")
 	}
 
 	// 绑定 Flags
@@ -46,6 +67,9 @@ func main() {
 	flag.BoolVar(&showVersion, "version", false, "Show version")
 	flag.BoolVar(&forceMode, "f", false, "Force mode")
 	flag.BoolVar(&forceMode, "force", false, "Force mode")
+	flag.StringVar(&diffRef, "diff", "", "Compare against git ref")
+	flag.BoolVar(&lazyMode, "lazy", false, "Load folders on demand")
+	limits.register(flag.CommandLine)
 
 	flag.Parse()
 
@@ -63,23 +87,27 @@ func main() {
 		targetPath = flag.Arg(0)
 	}
 
-	// 转换为绝对路径
-	absPath, err := filepath.Abs(targetPath)
+	// 转换为绝对路径并验证路径有效性
+	absPath, err := resolveTargetPath(targetPath)
 	if err != nil {
-		fmt.Printf("Error resolving path: %v\n", err)
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	// 验证路径有效性
-	info, err := os.Stat(absPath)
-	if err != nil || !info.IsDir() {
-		fmt.Printf("[Error] Path '%s' is not a valid directory.\n", absPath)
+	// 合并用户配置与 .gentr.json 中的设置 (优先级: 命令行参数 > .gentr.json > 用户配置 > 默认值)
+	// 按键绑定有冲突时拒绝启动
+	settings, _, err := loadSettings(absPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	keys, err := loadKeyMap(settings)
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
 	// 处理配置选项与 Force 模式交互
-	opts := core.DefaultOptions()
-
 	if forceMode {
 		fmt.Println("⚠️  WARNING: Force Mode Enabled")
 		fmt.Println("------------------------------------------------")
@@ -99,32 +127,71 @@ func main() {
 			os.Exit(0)
 		}
 
-		opts = core.ForceOptions()
+		settings.ApplyForce("--force")
 		fmt.Println("[!]Starting in Force Mode...")
 	}
 
-	// 扫描文件
-	rootNode, limitReached, err := core.Walk(absPath, opts)
-	if err != nil {
-		fmt.Printf("Error scanning directory: %v\n", err)
-		os.Exit(1)
+	// 显式给出的 --max-files 等参数优先 (包括 Force 模式的取值)
+	if err := limits.apply(flag.CommandLine, &settings); err != nil {
+		fmt.Printf("[Error] %v\n", err)
+		os.Exit(2)
+	}
+	if lazyMode {
+		settings.SetLazy("--lazy")
 	}
+	opts := settings.WalkOptions()
 
-	// 如果有则加载持久化配置
-	// 会修改 rootNode 里的 Annotation/Hidden/Collapsed 状态
-	// 使用 absPath 作为配置加载路径
-	core.LoadConfig(absPath, rootNode)
+	// 对比模式：Git 状态来自 git diff <ref>
+	opts.DiffRef = diffRef
 
-	// 初始化 UI 模型：传入 Version 以便进行更新检查
-	initialModel := ui.InitialModel(rootNode, limitReached, Version)
+	// 在后台清理回收目录中过期的文件，不影响启动
+	go core.PruneTrash(core.TrashMaxAge)
+
+	// 初始化扫描界面：在后台扫描并显示进度，完成后自动切换到主界面
+	// 传入 Version 以便进行更新检查
+	initialModel := ui.NewScanModel(absPath, opts, Version)
+	initialModel.Keys = keys
+	initialModel.Settings = settings
 
 	// 创建 Bubble Tea 程序并运行
 	// 使用 tea.WithAltScreen() 确保程序由框架接管全屏模式
 	// 这样退出时框架会自动恢复终端状态，解决无法打字的问题
-	p := tea.NewProgram(initialModel, tea.WithAltScreen())
+	// tea.WithMouseCellMotion() 开启鼠标点击与滚轮事件
+	p := tea.NewProgram(initialModel, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
-	if _, err := p.Run(); err != nil {
+	finalModel, err := p.Run()
+	if err != nil {
 		fmt.Printf("[ERROR]: %v", err)
 		os.Exit(1)
 	}
+
+	// 扫描阶段退出：区分用户取消与扫描失败
+	if scan, ok := finalModel.(ui.ScanModel); ok && scan.Err != nil {
+		if scan.Cancelled() {
+			fmt.Println("Scan cancelled.")
+			os.Exit(130)
+		}
+		fmt.Printf("Error scanning directory: %v\n", scan.Err)
+		os.Exit(1)
+	}
+
+	// 退出前的最后一次保存失败时提示用户，避免注释静默丢失
+	if app, ok := finalModel.(ui.MainModel); ok && app.SaveErr != nil {
+		fmt.Printf("Error saving %s: %v\n", core.ConfigFileName, app.SaveErr)
+		os.Exit(1)
+	}
+}
+
+// resolveTargetPath 将目标路径转换为绝对路径，并确认它是一个有效的目录
+func resolveTargetPath(targetPath string) (string, error) {
+	absPath, err := filepath.Abs(targetPath)
+	if err != nil {
+		return "", fmt.Errorf("Error resolving path: %v", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("[Error] Path '%s' is not a valid directory.", absPath)
+	}
+	return absPath, nil
 }
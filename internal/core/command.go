@@ -0,0 +1,122 @@
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Command 是在用户配置或 .gentr.json 中定义的自定义命令，例如:
+//
+//	{"key": "T", "name": "Test package", "run": "go test {dir}", "wait": true}
+//
+// run 通过系统 shell 执行，支持的占位符见 ExpandCommand
+// .gentr.json 随项目共享，其中的命令第一次运行前需要用户确认 (见 TrustCommand)
+type Command struct {
+	Key  string `json:"key"`            // 导航模式下触发命令的按键，不能覆盖内置按键
+	Name string `json:"name,omitempty"` // 显示在状态栏中的名称
+	Run  string `json:"run"`
+	Wait bool   `json:"wait,omitempty"` // 命令结束后等待回车再返回，方便查看输出
+
+	Source string `json:"-"` // 定义命令的配置 (SourceUser 或 SourceProject)
+}
+
+// Title 返回命令的显示名称，未设置 name 时使用命令本身
+func (c Command) Title() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Run
+}
+
+// ExpandCommand 替换命令中的占位符，替换值会按 shell 规则加引号:
+//
+//	{path} 节点的绝对路径
+//	{dir}  节点所在的文件夹 (节点是文件夹时为其自身)
+//	{name} 节点的文件名
+//	{rel}  节点相对扫描根目录的路径
+//	{root} 扫描根目录
+func ExpandCommand(run, rootPath, path string, isDir bool) string {
+	dir := path
+	if !isDir {
+		dir = filepath.Dir(path)
+	}
+	rel, err := filepath.Rel(rootPath, path)
+	if err != nil {
+		rel = path
+	}
+
+	replacer := strings.NewReplacer(
+		"{path}", shellQuote(path),
+		"{dir}", shellQuote(dir),
+		"{name}", shellQuote(filepath.Base(path)),
+		"{rel}", shellQuote(filepath.ToSlash(rel)),
+		"{root}", shellQuote(rootPath),
+	)
+	return replacer.Replace(run)
+}
+
+// ShellCommand 构造通过系统 shell 执行脚本的命令
+// wait 为 true 时命令结束后等待回车，避免输出被 TUI 立即覆盖
+func ShellCommand(script string, wait bool) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		if wait {
+			script += " & pause"
+		}
+		return exec.Command("cmd", "/C", script)
+	}
+	if wait {
+		script += "\nprintf '\\n[Press Enter to return to gentr]'; read _"
+	}
+	return exec.Command("sh", "-c", script)
+}
+
+// EditorCommand 构造用 $VISUAL / $EDITOR 打开文件的命令，都未设置时使用 vi (Windows 下为 notepad)
+// 编辑器变量可以带参数，例如 "code --wait"
+func EditorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	args := strings.Fields(editor)
+	args = append(args, path)
+	return exec.Command(args[0], args[1:]...)
+}
+
+// InteractiveShell 构造在 dir 中打开的交互式 shell，优先使用 $SHELL (Windows 下为 %ComSpec%)
+func InteractiveShell(dir string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		shell := os.Getenv("ComSpec")
+		if shell == "" {
+			shell = "cmd"
+		}
+		cmd = exec.Command(shell)
+	} else {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "sh"
+		}
+		cmd = exec.Command(shell)
+	}
+	cmd.Dir = dir
+	return cmd
+}
+
+// shellQuote 为 shell 参数加引号，Windows 下使用双引号
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
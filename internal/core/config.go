@@ -1,9 +1,12 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/DoraleCitrus/gentr/internal/model"
 )
@@ -15,59 +18,146 @@ type NodeConfig struct {
 	Annotation string `json:"annotation,omitempty"` // omitempty: 如果为空就不存，节省空间
 	Collapsed  bool   `json:"collapsed,omitempty"`
 	Hidden     bool   `json:"hidden,omitempty"`
+	Hash       string `json:"hash,omitempty"` // 带注释文件的内容哈希，文件被移动后用于重新关联
 }
 
 // ConfigFile 是最终存入 JSON 的结构
 type ConfigFile struct {
 	// Key 是文件的相对路径 (例如 "cmd/main.go")
 	Nodes map[string]NodeConfig `json:"nodes"`
+	// 项目级设置 (扫描限制、忽略规则、自定义命令等)，优先于用户配置
+	Settings
+}
+
+// Orphan 是路径已失效 (文件被删除或移动) 的配置条目
+type Orphan struct {
+	Path   string // 配置文件中记录的相对路径
+	Config NodeConfig
+}
+
+// ConfigState 记录加载配置时没能应用到树上的条目，保存时原样写回，避免丢失
+type ConfigState struct {
+	Orphans    []Orphan              // 路径在磁盘上已不存在，且未能自动重新关联的条目
+	Unseen     map[string]NodeConfig // 路径仍然存在但不在树中的条目 (例如超出扫描限制)
+	Reattached int                   // 本次加载自动重新关联的条目数
+	Settings   Settings              // 项目级设置，保存时原样写回
+	Folded     map[string]bool       // 懒加载模式下尚未读取的文件夹在配置中是否折叠，保存时原样写回
+
+	hashes map[string]fileHash // 带注释文件的内容哈希缓存 (绝对路径 -> 哈希)，避免每次保存都重新读取
+}
+
+// fileHash 是缓存的文件内容哈希，文件大小与修改时间不变时复用
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
 }
 
 // LoadConfig 读取配置文件并将其应用到现有的树结构上
-func LoadConfig(rootPath string, rootNode *model.Node) {
+// 路径失效的条目会尝试通过 Git 重命名记录和内容哈希重新关联，其余的记录在返回的 ConfigState 中
+func LoadConfig(rootPath string, rootNode *model.Node) *ConfigState {
+	state := &ConfigState{Unseen: make(map[string]NodeConfig)}
 	configPath := filepath.Join(rootPath, ConfigFileName)
 
 	// 1. 读取文件
 	data, err := os.ReadFile(configPath)
 	if os.IsNotExist(err) {
-		return // 文件不存在，直接跳过
+		return state // 文件不存在，直接跳过
 	}
 	if err != nil {
-		return // 读取错误也跳过，不做处理
+		return state // 读取错误也跳过，不做处理
 	}
 
 	// 2. 解析 JSON
 	var config ConfigFile
 	if err := json.Unmarshal(data, &config); err != nil {
-		return
+		return state
+	}
+
+	state.Settings = config.Settings
+
+	// 3. 按相对路径应用配置
+	nodes := indexNodes(rootNode, rootPath)
+	var orphans []Orphan
+	for relPath, conf := range config.Nodes {
+		if node, ok := nodes[relPath]; ok {
+			state.apply(relPath, node, conf)
+			continue
+		}
+
+		// 不在树中：区分文件仍然存在 (未扫描到) 与已经失效
+		if _, err := os.Lstat(filepath.Join(rootPath, filepath.FromSlash(relPath))); err == nil {
+			state.Unseen[relPath] = conf
+		} else {
+			orphans = append(orphans, Orphan{Path: relPath, Config: conf})
+		}
 	}
 
-	// 3. 递归遍历树，应用配置
-	applyConfig(rootNode, rootPath, config.Nodes)
+	// 4. 尝试为失效条目找到新位置
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	state.Orphans, state.Reattached = reattachOrphans(rootPath, nodes, config.Nodes, orphans)
+	return state
 }
 
-func applyConfig(node *model.Node, rootPath string, configMap map[string]NodeConfig) {
-	// 计算当前节点的相对路径
-	// node.Path 是绝对路径，我们需要把它变成相对于项目根目录的路径
-	relPath, err := filepath.Rel(rootPath, node.Path)
-	if err == nil {
-		// 为了跨平台兼容，统一把路径分隔符转为 "/"
-		relPath = filepath.ToSlash(relPath)
+// indexNodes 建立 相对路径 -> 节点 的索引 (跳过幽灵节点，它们在磁盘上已不存在)
+func indexNodes(root *model.Node, rootPath string) map[string]*model.Node {
+	nodes := make(map[string]*model.Node)
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		if node.Ghost {
+			return
+		}
+		// node.Path 是绝对路径，我们需要把它变成相对于项目根目录的路径
+		if relPath, err := filepath.Rel(rootPath, node.Path); err == nil {
+			// 为了跨平台兼容，统一把路径分隔符转为 "/"
+			nodes[filepath.ToSlash(relPath)] = node
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+func applyNodeConfig(node *model.Node, conf NodeConfig) {
+	node.Annotation = conf.Annotation
+	node.Collapsed = conf.Collapsed || node.Unloaded // 未读取的文件夹保持折叠，展开时才读取
+	node.Hidden = conf.Hidden
+}
 
-		if conf, ok := configMap[relPath]; ok {
-			node.Annotation = conf.Annotation
-			node.Collapsed = conf.Collapsed
-			node.Hidden = conf.Hidden
+// apply 把配置应用到节点上，并记录未读取的文件夹原本的折叠状态
+func (s *ConfigState) apply(relPath string, node *model.Node, conf NodeConfig) {
+	applyNodeConfig(node, conf)
+	if node.Unloaded && conf.Collapsed {
+		if s.Folded == nil {
+			s.Folded = make(map[string]bool)
 		}
+		s.Folded[relPath] = true
 	}
+}
 
-	for _, child := range node.Children {
-		applyConfig(child, rootPath, configMap)
+// ApplyLoaded 把配置应用到懒加载读取的子节点上 (加载配置时它们还不在树中，记录在 Unseen 里)
+func (s *ConfigState) ApplyLoaded(rootPath string, nodes []*model.Node) {
+	if s == nil {
+		return
+	}
+	for _, node := range nodes {
+		relPath, err := filepath.Rel(rootPath, node.Path)
+		if err != nil {
+			continue
+		}
+		relPath = filepath.ToSlash(relPath)
+		if conf, ok := s.Unseen[relPath]; ok {
+			s.apply(relPath, node, conf)
+			delete(s.Unseen, relPath)
+		}
 	}
 }
 
 // SaveConfig 收集当前树的状态并写入文件
-func SaveConfig(rootPath string, rootNode *model.Node) error {
+// state 中未应用到树上的条目会一并写回，state 可以为 nil
+func SaveConfig(rootPath string, rootNode *model.Node, state *ConfigState) error {
 	configPath := filepath.Join(rootPath, ConfigFileName)
 
 	config := ConfigFile{
@@ -75,33 +165,123 @@ func SaveConfig(rootPath string, rootNode *model.Node) error {
 	}
 
 	// 1. 递归收集状态
-	collectConfig(rootNode, rootPath, config.Nodes)
+	collectConfig(rootNode, rootPath, config.Nodes, state)
 
-	// 2. 序列化为 JSON (Indent 让文件人类可读)
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
+	// 保留不在树中的条目，树中的状态优先
+	if state != nil {
+		for relPath, conf := range state.Unseen {
+			if _, ok := config.Nodes[relPath]; !ok {
+				config.Nodes[relPath] = conf
+			}
+		}
+		for _, orphan := range state.Orphans {
+			if _, ok := config.Nodes[orphan.Path]; !ok {
+				config.Nodes[orphan.Path] = orphan.Config
+			}
+		}
+		config.Settings = state.Settings
+	}
+
+	// 2. 序列化为 JSON (Indent 让文件人类可读，不转义命令中的 "<" ">" "&")
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
 		return err
 	}
 
 	// 3. 写入文件
-	return os.WriteFile(configPath, data, 0644)
+	return writeFileAtomic(configPath, buf.Bytes(), 0644)
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，避免中途失败留下损坏的配置文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// 任何一步失败都清理临时文件
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
 }
 
-func collectConfig(node *model.Node, rootPath string, configMap map[string]NodeConfig) {
+// collectConfig 递归收集需要保存的节点状态
+// 懒加载模式下未读取的文件夹在树中总是折叠的，保存 state.Folded 中记录的原本的折叠状态
+func collectConfig(node *model.Node, rootPath string, configMap map[string]NodeConfig, state *ConfigState) {
+	relPath, err := filepath.Rel(rootPath, node.Path)
+	relPath = filepath.ToSlash(relPath)
+
+	collapsed := node.Collapsed
+	if node.Unloaded && state != nil {
+		collapsed = state.Folded[relPath]
+	}
+
 	// 只有当节点有状态改变时才保存（节省空间）
-	if node.Annotation != "" || node.Collapsed || node.Hidden {
-		relPath, err := filepath.Rel(rootPath, node.Path)
+	if node.Annotation != "" || collapsed || node.Hidden {
 		if err == nil {
-			relPath = filepath.ToSlash(relPath)
-			configMap[relPath] = NodeConfig{
+			conf := NodeConfig{
 				Annotation: node.Annotation,
-				Collapsed:  node.Collapsed,
+				Collapsed:  collapsed,
 				Hidden:     node.Hidden,
 			}
+			// 记录带注释文件的内容哈希，文件被移动后仍然能找回注释
+			if node.Annotation != "" && !node.IsDir && !node.Ghost {
+				conf.Hash = state.hashFile(node.Path)
+			}
+			configMap[relPath] = conf
 		}
 	}
 
 	for _, child := range node.Children {
-		collectConfig(child, rootPath, configMap)
+		collectConfig(child, rootPath, configMap, state)
+	}
+}
+
+// hashFile 返回文件的内容哈希，文件大小与修改时间没有变化时使用缓存 (state 可以为 nil)
+func (s *ConfigState) hashFile(filePath string) string {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ""
+	}
+	if s != nil {
+		if cached, ok := s.hashes[filePath]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+			return cached.hash
+		}
+	}
+
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return ""
+	}
+	if s != nil {
+		if s.hashes == nil {
+			s.hashes = make(map[string]fileHash)
+		}
+		s.hashes[filePath] = fileHash{size: info.Size(), modTime: info.ModTime(), hash: hash}
 	}
+	return hash
 }
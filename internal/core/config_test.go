@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigCommittedRename(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	root := initRepo(t, map[string]string{"old.go": "package main\n\nfunc main() {}\n"})
+	writeRepoFile(t, root, ConfigFileName, `{"nodes": {"old.go": {"annotation": "entry point"}, "gone.go": {"annotation": "lost"}}}`)
+	runGit(t, root, "mv", "old.go", "new.go")
+	runGit(t, root, "commit", "-q", "-m", "rename")
+
+	tree, _, err := Walk(context.Background(), root, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := LoadConfig(root, tree)
+	if state.Reattached != 1 || len(state.Orphans) != 1 || state.Orphans[0].Path != "gone.go" {
+		t.Fatalf("Reattached = %d, Orphans = %v, want 1 and [gone.go]", state.Reattached, state.Orphans)
+	}
+	if node := indexNodes(tree, root)["new.go"]; node == nil || node.Annotation != "entry point" {
+		t.Errorf("new.go did not get the annotation of old.go")
+	}
+
+	// 同一个 HEAD 上已经为剩下的条目查过重命名历史，新的条目需要重新查
+	head := gitHead(root)
+	if head == "" {
+		t.Fatal("gitHead returned nothing")
+	}
+	if !renameScanDone(root, head, state.Orphans) {
+		t.Error("rename scan should be recorded for the remaining orphans")
+	}
+	if renameScanDone(root, head, append(state.Orphans, Orphan{Path: "other.go"})) {
+		t.Error("a new orphan should trigger another rename scan")
+	}
+	if renameScanDone(root, "0000000", state.Orphans) {
+		t.Error("a new HEAD should trigger another rename scan")
+	}
+}
+
+func TestConfigHashCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	writeFile(t, path, "hello")
+	state := &ConfigState{}
+
+	first := state.hashFile(path)
+	if first == "" {
+		t.Fatal("hashFile returned nothing")
+	}
+
+	// 大小与修改时间不变时使用缓存 (用一个假的哈希验证没有重新读取)
+	cached := state.hashes[path]
+	cached.hash = "cached"
+	state.hashes[path] = cached
+	if got := state.hashFile(path); got != "cached" {
+		t.Errorf("unchanged file: hashFile = %q, want the cached hash", got)
+	}
+
+	// 修改时间变化后重新计算
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.hashFile(path); got != first {
+		t.Errorf("touched file: hashFile = %q, want %q", got, first)
+	}
+
+	// 没有 ConfigState 时直接计算
+	var none *ConfigState
+	if got := none.hashFile(path); got != first {
+		t.Errorf("nil state: hashFile = %q, want %q", got, first)
+	}
+}
@@ -0,0 +1,386 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// 文件操作：同时修改磁盘与内存中的树，调用方负责随后刷新 Git 状态并保存配置
+// 所有目标路径都是相对扫描根目录的路径 (以 "/" 分隔)，不允许指向根目录之外
+
+// CreatePath 创建文件 (isDir 为 true 时创建文件夹)，缺失的父文件夹会一并创建
+func CreatePath(root *model.Node, relPath string, isDir bool) (*model.Node, error) {
+	abs, relPath, err := resolveRel(root.Path, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		return nil, fmt.Errorf("%s already exists", relPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, err
+	}
+	if isDir {
+		err = os.Mkdir(abs, 0755)
+	} else {
+		var f *os.File
+		f, err = os.OpenFile(abs, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node := &model.Node{Name: filepath.Base(abs), Path: abs, IsDir: isDir}
+	attachNode(ensureDirNodes(root, filepath.Dir(abs)), node)
+	return node, nil
+}
+
+// MovePath 把节点移动 (或重命名) 到 relPath，子孙节点的路径随之更新
+func MovePath(root, node *model.Node, relPath string) error {
+	abs, relPath, err := resolveRel(root.Path, relPath)
+	if err != nil {
+		return err
+	}
+	if abs == node.Path {
+		return fmt.Errorf("source and destination are the same")
+	}
+	if node.IsDir && strings.HasPrefix(abs, node.Path+string(filepath.Separator)) {
+		return fmt.Errorf("cannot move a folder into itself")
+	}
+	// 只改变大小写的重命名在不区分大小写的文件系统上会找到自身
+	if _, err := os.Lstat(abs); err == nil && !strings.EqualFold(abs, node.Path) {
+		return fmt.Errorf("%s already exists", relPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return err
+	}
+	if err := movePath(node.Path, abs); err != nil {
+		return err
+	}
+
+	detachNode(root, node)
+	setNodePath(node, abs)
+	attachNode(ensureDirNodes(root, filepath.Dir(abs)), node)
+	return nil
+}
+
+// CopyPath 把节点复制到 relPath，并按 opts 的忽略规则与限制扫描出副本的子树
+func CopyPath(root, node *model.Node, relPath string, opts WalkOptions) (*model.Node, error) {
+	abs, relPath, err := resolveRel(root.Path, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if node.IsDir && strings.HasPrefix(abs, node.Path+string(filepath.Separator)) {
+		return nil, fmt.Errorf("cannot copy a folder into itself")
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		return nil, fmt.Errorf("%s already exists", relPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, err
+	}
+	if err := copyPath(node.Path, abs); err != nil {
+		os.RemoveAll(abs) // 清理复制了一半的副本
+		return nil, err
+	}
+
+	copied, _, err := Walk(context.Background(), abs, opts)
+	if err != nil {
+		os.RemoveAll(abs) // 副本不在树中也没有撤销记录，不能留在磁盘上
+		return nil, err
+	}
+	attachNode(ensureDirNodes(root, filepath.Dir(abs)), copied)
+	return copied, nil
+}
+
+// TrashPath 把节点移动到回收目录 (见 TrashDir) 并从树中移除，返回其在回收目录中的路径
+func TrashPath(root, node *model.Node) (string, error) {
+	trashDir, err := TrashDir()
+	if err != nil {
+		return "", err
+	}
+
+	// 每次删除使用独立的子文件夹，避免同名文件互相覆盖
+	dir := filepath.Join(trashDir, strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, node.Name)
+	if err := movePath(node.Path, target); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+
+	detachNode(root, node)
+	return target, nil
+}
+
+// TrashDir 返回被删除文件的回收目录 (用户缓存目录下的 gentr/trash)
+func TrashDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "gentr", "trash"), nil
+}
+
+// TrashMaxAge 是回收目录中的文件保留的时间，更早删除的文件由 PruneTrash 清理
+const TrashMaxAge = 30 * 24 * time.Hour
+
+// PruneTrash 永久删除回收目录中早于 maxAge 被删除的文件，返回清理的数量
+// 撤销历史中指向已清理文件的删除操作无法再撤销 (撤销时报错并从历史中丢弃)
+func PruneTrash(maxAge time.Duration) (int, error) {
+	trashDir, err := TrashDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// 子文件夹以删除时的时间戳 (纳秒) 命名，见 TrashPath
+	cutoff := time.Now().Add(-maxAge).UnixNano()
+	pruned := 0
+	for _, entry := range entries {
+		stamp, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() || stamp >= cutoff {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashDir, entry.Name())); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// MigrateConfig 让不在树中的配置条目跟随重命名，newRel 为空表示路径已被删除
+// 树中节点的状态保存在节点上，会随节点一起移动，不需要迁移
+// 未读取文件夹的折叠状态与失效条目同样跟随；删除时失效条目保留在列表中，等待用户处理
+func MigrateConfig(state *ConfigState, oldRel, newRel string) {
+	if state == nil {
+		return
+	}
+	migrateKeys(state.Unseen, oldRel, newRel)
+	migrateKeys(state.Folded, oldRel, newRel)
+	if newRel == "" {
+		return
+	}
+	for i, orphan := range state.Orphans {
+		if rest, ok := cutPathPrefix(orphan.Path, oldRel); ok {
+			state.Orphans[i].Path = newRel + rest
+		}
+	}
+	sort.Slice(state.Orphans, func(i, j int) bool { return state.Orphans[i].Path < state.Orphans[j].Path })
+}
+
+// migrateKeys 把 oldRel 及其下的键改到 newRel 之下，newRel 为空时删除
+func migrateKeys[V any](m map[string]V, oldRel, newRel string) {
+	moved := make(map[string]V)
+	for relPath, v := range m {
+		rest, ok := cutPathPrefix(relPath, oldRel)
+		if !ok {
+			continue
+		}
+		delete(m, relPath)
+		if newRel != "" {
+			moved[newRel+rest] = v
+		}
+	}
+	for relPath, v := range moved {
+		m[relPath] = v
+	}
+}
+
+// NodeRelPath 返回节点相对扫描根目录的路径 (以 "/" 分隔)
+func NodeRelPath(root, node *model.Node) string {
+	relPath, err := filepath.Rel(root.Path, node.Path)
+	if err != nil {
+		return node.Path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// cutPathPrefix 判断 path 是否为 prefix 本身或位于其下，返回剩余部分 (以 "/" 开头或为空)
+func cutPathPrefix(path, prefix string) (string, bool) {
+	if path == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(path, prefix+"/") {
+		return path[len(prefix):], true
+	}
+	return "", false
+}
+
+// resolveRel 把相对路径转为绝对路径，并检查它位于根目录之内
+func resolveRel(rootPath, relPath string) (string, string, error) {
+	relPath = strings.TrimSpace(relPath)
+	if relPath == "" {
+		return "", "", fmt.Errorf("empty path")
+	}
+	if filepath.IsAbs(relPath) {
+		return "", "", fmt.Errorf("path must be relative to the project root")
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(relPath))
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path must stay inside the project root")
+	}
+	for _, part := range strings.Split(cleaned, string(filepath.Separator)) {
+		if strings.EqualFold(part, ".git") {
+			return "", "", fmt.Errorf("refusing to touch .git")
+		}
+	}
+	return filepath.Join(rootPath, cleaned), filepath.ToSlash(cleaned), nil
+}
+
+// ensureDirNodes 返回 dir 对应的文件夹节点，缺失的节点会被创建
+// 同名的幽灵文件夹 (已删除后又重新创建) 会恢复为普通节点
+func ensureDirNodes(root *model.Node, dir string) *model.Node {
+	relPath, err := filepath.Rel(root.Path, dir)
+	if err != nil || relPath == "." {
+		return root
+	}
+
+	parent := root
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		var found *model.Node
+		for _, child := range parent.Children {
+			if child.Name == part && child.IsDir {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			found = &model.Node{Name: part, Path: filepath.Join(parent.Path, part), IsDir: true}
+			attachNode(parent, found)
+		}
+		found.Ghost = false
+		parent = found
+	}
+	return parent
+}
+
+// attachNode 把节点按文件名顺序插入 parent，替换同名的幽灵节点
+func attachNode(parent, node *model.Node) {
+	kept := parent.Children[:0]
+	for _, child := range parent.Children {
+		if child.Ghost && child.Name == node.Name {
+			continue
+		}
+		kept = append(kept, child)
+	}
+	parent.Children = kept
+	insertChildSorted(parent, node)
+}
+
+// detachNode 把节点从其父节点中移除
+func detachNode(root, node *model.Node) bool {
+	for i, child := range root.Children {
+		if child == node {
+			root.Children = append(root.Children[:i:i], root.Children[i+1:]...)
+			return true
+		}
+		if child.IsDir && detachNode(child, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// setNodePath 更新节点及其子孙的路径
+func setNodePath(node *model.Node, path string) {
+	node.Path = path
+	node.Name = filepath.Base(path)
+	for _, child := range node.Children {
+		setNodePath(child, filepath.Join(path, child.Name))
+	}
+}
+
+// movePath 重命名文件或文件夹，跨文件系统时退化为复制后删除
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if copyErr := copyPath(src, dst); copyErr != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyPath 递归复制文件或文件夹，保留权限位，符号链接按链接本身复制
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return copyFile(src, dst, info.Mode().Perm())
+	}
+}
+
+// copyFile 复制单个文件
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResolveRel(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	tests := []struct {
+		in      string
+		wantRel string // 为空表示应当报错
+	}{
+		{"src/main.go", "src/main.go"},
+		{" ./src//a/../b.go ", "src/b.go"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../outside", ""},
+		{"src/../../outside", ""},
+		{"/etc/passwd", ""},
+		{".git", ""},
+		{".git/config", ""},
+		{"sub/.git/hooks/pre-commit", ""},
+		{"sub/.GIT/config", ""},
+		{".github/workflows/ci.yml", ".github/workflows/ci.yml"},
+		{"docs/.gitignore", "docs/.gitignore"},
+	}
+	for _, tt := range tests {
+		abs, rel, err := resolveRel(root, tt.in)
+		if tt.wantRel == "" {
+			if err == nil {
+				t.Errorf("resolveRel(%q) = %q, want an error", tt.in, rel)
+			}
+			continue
+		}
+		if err != nil || rel != tt.wantRel || abs != filepath.Join(root, filepath.FromSlash(tt.wantRel)) {
+			t.Errorf("resolveRel(%q) = (%q, %q, %v), want rel %q", tt.in, abs, rel, err, tt.wantRel)
+		}
+	}
+}
+
+func TestPruneTrash(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir()) // macOS 不读取 XDG_CACHE_HOME
+	trashDir, err := TrashDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	old := strconv.FormatInt(now.Add(-2*TrashMaxAge).UnixNano(), 10)
+	recent := strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10)
+	for _, name := range []string{old, recent, "not-a-stamp"} {
+		if err := os.MkdirAll(filepath.Join(trashDir, name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(trashDir, name, "file.txt"), "x")
+	}
+
+	pruned, err := PruneTrash(TrashMaxAge)
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneTrash() = (%d, %v), want (1, nil)", pruned, err)
+	}
+	for name, want := range map[string]bool{old: false, recent: true, "not-a-stamp": true} {
+		if _, err := os.Stat(filepath.Join(trashDir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestCopyPathRemovesCopyWhenScanFails(t *testing.T) {
+	root := initRepo(t, map[string]string{"src/a.go": "package src\n"})
+	tree, _, err := Walk(context.Background(), root, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := tree.Children[0]
+
+	// 对比的 ref 不存在时扫描副本失败，副本不能留在磁盘上
+	opts := DefaultOptions()
+	opts.DiffRef = "no-such-ref"
+	if _, err := CopyPath(tree, src, "copy", opts); err == nil {
+		t.Fatal("CopyPath with an unknown diff ref should fail")
+	}
+	if _, err := os.Lstat(filepath.Join(root, "copy")); !os.IsNotExist(err) {
+		t.Errorf("copy left on disk after a failed scan: %v", err)
+	}
+
+	copied, err := CopyPath(tree, src, "copy", DefaultOptions())
+	if err != nil || copied.Path != filepath.Join(root, "copy") {
+		t.Fatalf("CopyPath() = (%v, %v)", copied, err)
+	}
+}
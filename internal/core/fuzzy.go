@@ -0,0 +1,123 @@
+package core
+
+import (
+	"unicode"
+)
+
+// 模糊匹配的打分参数 (参考 fzf 的 v1 算法)
+const (
+	scoreMatch        = 16 // 每个匹配字符的基础分
+	scoreGapStart     = -3 // 两个匹配字符之间出现间隔
+	scoreGapExtension = -1 // 间隔每多一个字符
+	bonusBoundary     = 8  // 匹配字符位于单词开头 (路径分隔符、"_"、"-"、"." 或空格之后)
+	bonusCamel        = 7  // 匹配字符是驼峰命名中的大写字母
+	bonusConsecutive  = 4  // 与上一个匹配字符相邻
+	bonusFirstChar    = 2  // 第一个匹配字符的加成倍数
+	bonusBaseName     = 2  // 匹配字符位于文件名 (路径最后一段) 中
+)
+
+// FuzzyResult 是一次模糊匹配的结果
+type FuzzyResult struct {
+	Score     int
+	Positions []int // 匹配字符在 text 中的下标 (按 rune 计算)，升序
+}
+
+// FuzzyMatch 判断 pattern 的字符是否按顺序出现在 text 中 (子序列匹配)，并为匹配打分
+// 使用智能大小写：pattern 全部小写时忽略大小写，否则区分大小写
+// 先正向找到最早的完整匹配，再从结尾反向收缩，得到最短的匹配区间
+func FuzzyMatch(pattern, text string) (FuzzyResult, bool) {
+	p := []rune(pattern)
+	t := []rune(text)
+	if len(p) == 0 {
+		return FuzzyResult{}, true
+	}
+
+	caseSensitive := false
+	for _, r := range p {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	equal := func(a, b rune) bool {
+		if caseSensitive {
+			return a == b
+		}
+		return unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// 1. 正向扫描，找到完整匹配的结束位置
+	pi, end := 0, -1
+	for ti := 0; ti < len(t); ti++ {
+		if equal(t[ti], p[pi]) {
+			pi++
+			if pi == len(p) {
+				end = ti
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return FuzzyResult{}, false
+	}
+
+	// 2. 从结束位置反向扫描，尽量靠后地匹配每个字符，得到最短区间
+	positions := make([]int, len(p))
+	pi = len(p) - 1
+	for ti := end; ti >= 0 && pi >= 0; ti-- {
+		if equal(t[ti], p[pi]) {
+			positions[pi] = ti
+			pi--
+		}
+	}
+
+	return FuzzyResult{Score: fuzzyScore(t, positions), Positions: positions}, true
+}
+
+// fuzzyScore 为一组匹配位置打分
+func fuzzyScore(t []rune, positions []int) int {
+	// 文件名 (最后一个 "/" 之后) 的起始位置
+	baseStart := 0
+	for i := len(t) - 1; i >= 0; i-- {
+		if t[i] == '/' {
+			baseStart = i + 1
+			break
+		}
+	}
+
+	score := 0
+	for i, pos := range positions {
+		bonus := charBonus(t, pos)
+		if i == 0 {
+			bonus *= bonusFirstChar
+		} else if gap := pos - positions[i-1] - 1; gap == 0 {
+			bonus += bonusConsecutive
+		} else {
+			score += scoreGapStart + scoreGapExtension*(gap-1)
+		}
+		if pos >= baseStart {
+			bonus += bonusBaseName
+		}
+		score += scoreMatch + bonus
+	}
+	return score
+}
+
+// charBonus 根据字符所处的位置 (单词开头、驼峰) 返回加分
+func charBonus(t []rune, pos int) int {
+	if pos == 0 {
+		return bonusBoundary
+	}
+	prev, cur := t[pos-1], t[pos]
+	switch prev {
+	case '/', '_', '-', '.', ' ':
+		return bonusBoundary
+	}
+	if unicode.IsLower(prev) && unicode.IsUpper(cur) {
+		return bonusCamel
+	}
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return bonusBoundary / 2
+	}
+	return 0
+}
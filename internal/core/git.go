@@ -2,17 +2,73 @@ package core
 
 import (
 	"bytes"
+	"fmt"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
 )
 
-// LoadGitStatus 返回一个 map，key 是文件的相对路径，value 是状态代码 ("M", "A", etc.)
-func LoadGitStatus(rootPath string) map[string]string {
-	statusMap := make(map[string]string)
+// GitEntry 是一个路径的完整 Git 状态，对应 porcelain 输出中的 XY 两列
+type GitEntry struct {
+	Index    byte   // 暂存区状态 (X)，' ' 表示无变化
+	Worktree byte   // 工作区状态 (Y)，' ' 表示无变化
+	OrigPath string // 重命名/复制的来源路径 (相对根目录，"/" 分隔)
+}
+
+// Code 将 XY 两列归纳为单个状态码，用于过滤与着色
+func (e GitEntry) Code() string {
+	x, y := e.Index, e.Worktree
+
+	switch {
+	// 冲突: DD, AU, UD, UA, DU, AA, UU
+	case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+		return model.GitConflict
+	case x == '!' && y == '!':
+		return model.GitIgnored
+	// 未追踪文件沿用 "A"，显示为 [+]
+	case x == '?' && y == '?':
+		return model.GitAdded
+	case x == 'R' || y == 'R':
+		return model.GitRenamed
+	case x == 'C' || y == 'C':
+		return model.GitCopied
+	case x == 'D' || y == 'D':
+		return model.GitDeleted
+	case x == 'T' || y == 'T':
+		return model.GitTypeChanged
+	case x == 'A' || y == 'A':
+		return model.GitAdded
+	case x == 'M' || y == 'M':
+		return model.GitModified
+	}
+	return ""
+}
+
+// LoadGitStatus 返回一个 map，key 是文件的相对路径，value 是完整的 Git 状态
+// 以 "/" 结尾的 key 表示整个文件夹 (未追踪或被忽略的目录)，其状态会被子孙节点继承
+// includeIgnored 为 true 时同时列出被忽略的文件 (用于无视 .gitignore 的 Force 模式)
+func LoadGitStatus(rootPath string, includeIgnored bool) map[string]GitEntry {
+	statusMap := make(map[string]GitEntry)
+
+	// 定位仓库根目录：porcelain 输出的路径相对于仓库根目录，而不是扫描目录
+	repo, err := FindGitRepo(rootPath)
+	if err != nil {
+		// 如果执行失败（例如未安装 git 或不是 git 仓库），返回空 map
+		return statusMap
+	}
 
 	// 尝试使用系统安装的 git 命令，因为它能更好地处理配置（如 core.filemode, core.autocrlf）
 	// 使用 -z 选项以 NUL 字符分隔输出，避免文件名包含特殊字符的问题
-	cmd := exec.Command("git", "status", "--porcelain", "-z")
+	// 不限定路径：重命名的来源可能位于扫描目录之外
+	args := []string{"status", "--porcelain", "-z"}
+	if includeIgnored {
+		args = append(args, "--ignored")
+	}
+	cmd := exec.Command("git", args...)
 	cmd.Dir = rootPath
 	output, err := cmd.Output()
 	if err != nil {
@@ -35,32 +91,332 @@ func LoadGitStatus(rootPath string) map[string]string {
 		// 路径从索引 3 开始 (跳过 "XY ")
 		path := string(entry[3:])
 
-		// 处理重命名 (R)：如果是重命名，下一个 entry 是原始路径，需要跳过
-		if x == 'R' {
+		gitEntry := GitEntry{Index: x, Worktree: y}
+
+		// 处理重命名 (R) 和复制 (C)：下一个 entry 是原始路径
+		if x == 'R' || x == 'C' || y == 'R' || y == 'C' {
+			if i+1 < len(entries) {
+				gitEntry.OrigPath = filepath.ToSlash(string(entries[i+1]))
+			}
+			i++
+		}
+
+		statusMap[filepath.ToSlash(path)] = gitEntry
+	}
+
+	return repo.translateStatus(statusMap)
+}
+
+// LoadGitDiff 计算工作区相对于任意 ref 的变更 (git diff --name-status)
+// ref 可以是分支、提交或 "main...HEAD" 这类 merge-base 形式
+// 对比模式下没有暂存区的概念，状态统一记录在工作区列
+func LoadGitDiff(rootPath, ref string) (map[string]GitEntry, error) {
+	statusMap := make(map[string]GitEntry)
+
+	repo, err := FindGitRepo(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用 "--" 明确 ref 是修订版本而不是路径
+	cmd := exec.Command("git", "diff", "--name-status", "-z", ref, "--")
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError("git diff "+ref, err)
+	}
+
+	// 解析输出
+	// 格式: STATUS\0PATH\0 或 R100\0ORIG_PATH\0PATH\0 (重命名/复制带相似度)
+	tokens := bytes.Split(output, []byte{0})
+	for i := 0; i < len(tokens); i++ {
+		status := tokens[i]
+		if len(status) == 0 || i+1 >= len(tokens) {
+			continue
+		}
+
+		gitEntry := GitEntry{Index: ' ', Worktree: status[0]}
+		path := string(tokens[i+1])
+		i++
+
+		if status[0] == 'R' || status[0] == 'C' {
+			if i+1 >= len(tokens) {
+				break
+			}
+			gitEntry.OrigPath = filepath.ToSlash(path)
+			path = string(tokens[i+1])
 			i++
 		}
 
-		var s string
-		// 映射状态
-		// ?? -> Untracked -> A
-		// A. -> Added -> A
-		// .A -> Added -> A
-		// M. -> Modified -> M
-		// .M -> Modified -> M
+		if gitEntry.Code() != "" {
+			statusMap[filepath.ToSlash(path)] = gitEntry
+		}
+	}
+
+	return repo.translateStatus(statusMap), nil
+}
+
+// LineStat 是一个文件新增/删除的行数
+type LineStat struct {
+	Added   int
+	Removed int
+}
+
+// LoadGitNumstat 读取每个文件的行数变化 (git diff --numstat)
+// ref 为空时对比 HEAD (包含已暂存与未暂存的改动)，二进制文件没有行数，会被跳过
+func LoadGitNumstat(rootPath, ref string) map[string]LineStat {
+	stats := make(map[string]LineStat)
+
+	repo, err := FindGitRepo(rootPath)
+	if err != nil {
+		return stats
+	}
 
-		if x == '?' && y == '?' {
-			s = "A"
-		} else if x == 'A' || y == 'A' {
-			s = "A"
-		} else if x == 'M' || y == 'M' {
-			s = "M"
+	base := ref
+	if base == "" {
+		base = "HEAD"
+	}
+	cmd := exec.Command("git", "diff", "--numstat", "-z", base, "--")
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil && ref == "" {
+		// 还没有任何提交的仓库没有 HEAD，退回到只统计未暂存的改动
+		cmd = exec.Command("git", "diff", "--numstat", "-z")
+		cmd.Dir = rootPath
+		output, err = cmd.Output()
+	}
+	if err != nil {
+		return stats
+	}
+
+	// 解析输出
+	// 格式: ADDED\tREMOVED\tPATH\0 或 ADDED\tREMOVED\t\0ORIG_PATH\0PATH\0 (重命名)
+	tokens := bytes.Split(output, []byte{0})
+	for i := 0; i < len(tokens); i++ {
+		fields := strings.SplitN(string(tokens[i]), "\t", 3)
+		if len(fields) < 3 {
+			continue
 		}
 
-		if s != "" {
-			cleanPath := filepath.ToSlash(path)
-			statusMap[cleanPath] = s
+		path := fields[2]
+		if path == "" {
+			// 重命名：接下来是原路径和新路径
+			if i+2 >= len(tokens) {
+				break
+			}
+			path = string(tokens[i+2])
+			i += 2
 		}
+
+		added, errA := strconv.Atoi(fields[0])
+		removed, errR := strconv.Atoi(fields[1])
+		if errA != nil || errR != nil {
+			continue // 二进制文件显示为 "-"
+		}
+		stats[filepath.ToSlash(path)] = LineStat{Added: added, Removed: removed}
 	}
 
-	return statusMap
+	return repo.translateLines(stats)
+}
+
+// GitChanges 是一次加载得到的全部 Git 信息
+type GitChanges struct {
+	Status map[string]GitEntry
+	Lines  map[string]LineStat
+}
+
+// LoadGitChanges 根据扫描选项加载 Git 状态与行数变化：
+// 指定了 DiffRef 时对比该 ref，否则读取 git status
+func LoadGitChanges(rootPath string, opts WalkOptions) (*GitChanges, error) {
+	changes := &GitChanges{}
+
+	if opts.DiffRef != "" {
+		status, err := LoadGitDiff(rootPath, opts.DiffRef)
+		if err != nil {
+			return nil, err
+		}
+		changes.Status = status
+	} else {
+		changes.Status = LoadGitStatus(rootPath, opts.IgnoreGitIgnore)
+	}
+
+	changes.Lines = LoadGitNumstat(rootPath, opts.DiffRef)
+	return changes, nil
+}
+
+// ApplyGitChanges 将状态与行数变化一起写入整棵树
+func ApplyGitChanges(root *model.Node, changes *GitChanges) {
+	ApplyGitStatus(root, changes.Status)
+	ApplyLineStats(root, changes.Lines)
+}
+
+// ApplyLineStats 写入文件的行数变化，并把文件夹的行数汇总为其子孙之和
+func ApplyLineStats(root *model.Node, stats map[string]LineStat) {
+	applyLinesRecursive(root, root.Path, stats)
+}
+
+// applyLinesRecursive 递归写入行数，返回节点 (含子孙) 的合计
+func applyLinesRecursive(node *model.Node, rootPath string, stats map[string]LineStat) LineStat {
+	var total LineStat
+
+	if node.IsDir {
+		for _, child := range node.Children {
+			childStat := applyLinesRecursive(child, rootPath, stats)
+			total.Added += childStat.Added
+			total.Removed += childStat.Removed
+		}
+	} else if relPath, err := filepath.Rel(rootPath, node.Path); err == nil {
+		total = stats[filepath.ToSlash(relPath)]
+	}
+
+	node.LinesAdded = total.Added
+	node.LinesRemoved = total.Removed
+	return total
+}
+
+// gitError 将 git 命令的错误包装为可读信息，优先使用 stderr 的内容
+func gitError(command string, err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return fmt.Errorf("%s: %s", command, firstLine(msg))
+		}
+	}
+	return fmt.Errorf("%s: %w", command, err)
+}
+
+// firstLine 返回多行文本的第一行
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// ApplyGitStatus 将 Git 状态写入整棵树
+// 会先清除旧的状态与幽灵节点，然后把已删除 (磁盘上不存在) 的文件作为幽灵节点插回树中
+func ApplyGitStatus(root *model.Node, statusMap map[string]GitEntry) {
+	clearGitStatus(root)
+
+	// 以 "/" 结尾的文件夹状态单独收集，用于继承
+	dirStatus := make(map[string]GitEntry)
+	for path, entry := range statusMap {
+		if strings.HasSuffix(path, "/") {
+			dirStatus[strings.TrimSuffix(path, "/")] = entry
+		}
+	}
+
+	applyGitRecursive(root, root.Path, statusMap, dirStatus, nil)
+
+	// 按路径排序后插入幽灵节点，保证结果稳定
+	var deleted []string
+	for path, entry := range statusMap {
+		if entry.Code() == model.GitDeleted || (entry.Code() == model.GitConflict && entry.Worktree == 'D') {
+			deleted = append(deleted, path)
+		}
+	}
+	sort.Strings(deleted)
+	for _, path := range deleted {
+		insertGhost(root, path, statusMap[path])
+	}
+}
+
+// clearGitStatus 递归清除 Git 状态，并移除上一次插入的幽灵节点
+func clearGitStatus(node *model.Node) {
+	setGitEntry(node, GitEntry{Index: ' ', Worktree: ' '})
+
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		if child.Ghost {
+			continue
+		}
+		clearGitStatus(child)
+		kept = append(kept, child)
+	}
+	node.Children = kept
+}
+
+// applyGitRecursive 递归注入 Git 状态，inherited 是来自祖先文件夹的状态
+func applyGitRecursive(node *model.Node, rootPath string, statusMap, dirStatus map[string]GitEntry, inherited *GitEntry) {
+	// 计算相对路径以便在 statusMap 中查找
+	relPath, err := filepath.Rel(rootPath, node.Path)
+	if err != nil {
+		return
+	}
+	relPath = filepath.ToSlash(relPath) // 统一为 "/" 分隔符，兼容不同系统
+
+	if entry, ok := statusMap[relPath]; ok {
+		setGitEntry(node, entry)
+	} else if entry, ok := dirStatus[relPath]; ok && node.IsDir {
+		setGitEntry(node, entry)
+		inherited = &entry
+	} else if inherited != nil {
+		setGitEntry(node, *inherited)
+	}
+
+	for _, child := range node.Children {
+		applyGitRecursive(child, rootPath, statusMap, dirStatus, inherited)
+	}
+}
+
+// setGitEntry 把 GitEntry 写入节点字段
+func setGitEntry(node *model.Node, entry GitEntry) {
+	node.GitStatus = entry.Code()
+	node.GitIndex = gitColumn(entry.Index)
+	node.GitWorktree = gitColumn(entry.Worktree)
+	node.GitOrigPath = entry.OrigPath
+}
+
+// gitColumn 将单列状态转为字符串，无变化时为空
+func gitColumn(c byte) string {
+	if c == ' ' || c == 0 {
+		return ""
+	}
+	return string(c)
+}
+
+// insertGhost 为已删除的文件创建幽灵节点，缺失的父文件夹同样以幽灵节点补齐
+func insertGhost(root *model.Node, relPath string, entry GitEntry) {
+	parts := strings.Split(relPath, "/")
+	parent := root
+
+	for i, part := range parts {
+		isLast := i == len(parts)-1
+
+		var found *model.Node
+		for _, child := range parent.Children {
+			if child.Name == part {
+				found = child
+				break
+			}
+		}
+
+		if found != nil {
+			// 文件仍然存在 (例如删除后又重新创建)，不需要幽灵节点
+			if isLast {
+				return
+			}
+			parent = found
+			continue
+		}
+
+		ghost := &model.Node{
+			Name:  part,
+			Path:  filepath.Join(parent.Path, part),
+			IsDir: !isLast,
+			Ghost: true,
+		}
+		setGitEntry(ghost, entry)
+		insertChildSorted(parent, ghost)
+		parent = ghost
+	}
+}
+
+// insertChildSorted 按文件名顺序插入子节点，与 os.ReadDir 的顺序保持一致
+func insertChildSorted(parent *model.Node, child *model.Node) {
+	idx := sort.Search(len(parent.Children), func(i int) bool {
+		return parent.Children[i].Name > child.Name
+	})
+	parent.Children = append(parent.Children, nil)
+	copy(parent.Children[idx+1:], parent.Children[idx:])
+	parent.Children[idx] = child
 }
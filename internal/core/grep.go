@@ -0,0 +1,193 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+const (
+	// MaxGrepFileSize 超过该大小的文件不搜索内容
+	MaxGrepFileSize = 8 * 1024 * 1024
+	// MaxGrepLines 每个文件最多保留的匹配行，用于预览 (计数不受限制)
+	MaxGrepLines = 100
+	// binarySniffSize 用于判断二进制文件的读取长度，与 git 的判断方式一致
+	binarySniffSize = 8000
+)
+
+// GrepLine 是文件中匹配的一行
+type GrepLine struct {
+	Number int    // 行号，从 1 开始
+	Text   string // 行内容 (去掉行尾换行)
+}
+
+// GrepFile 是单个文件的搜索结果
+type GrepFile struct {
+	Count int        // 匹配的行数
+	Lines []GrepLine // 最多 MaxGrepLines 行
+}
+
+// GrepPaths 返回树中所有需要搜索的文件路径 (跳过幽灵节点)
+// 只搜索已经扫描进树的文件，因此自动遵循扫描时的忽略规则
+// 树可能被 TUI 修改，需要在持有树的协程中调用，再把路径交给 Grep
+func GrepPaths(root *model.Node) []string {
+	var paths []string
+	var collect func(node *model.Node)
+	collect = func(node *model.Node) {
+		for _, child := range node.Children {
+			if child.Ghost {
+				continue
+			}
+			if child.IsDir {
+				collect(child)
+			} else {
+				paths = append(paths, child.Path)
+			}
+		}
+	}
+	collect(root)
+	return paths
+}
+
+// Grep 在给定的文件里搜索 pattern，返回 路径 -> 结果，只包含有匹配的文件
+// 跳过二进制文件和过大的文件；pattern 以 "re:" 开头时按正则表达式匹配，否则按字面量匹配；全部小写时忽略大小写
+func Grep(ctx context.Context, paths []string, pattern string) (map[string]*GrepFile, error) {
+	match, err := compileGrepPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// 工作池并发读取文件
+	results := make(map[string]*GrepFile)
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+
+	workers := runtime.NumCPU()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				// 取消后只消耗剩余任务，不再读取文件
+				if ctx.Err() != nil {
+					continue
+				}
+				result := grepFile(path, match)
+				if result == nil {
+					continue
+				}
+				mu.Lock()
+				results[path] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// compileGrepPattern 把搜索词编译为按行匹配的函数
+func compileGrepPattern(pattern string) (func(line []byte) bool, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	// 智能大小写：搜索词中没有大写字母时忽略大小写
+	ignoreCase := true
+	for _, r := range pattern {
+		if unicode.IsUpper(r) {
+			ignoreCase = false
+			break
+		}
+	}
+
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("re: %v", err)
+		}
+		return re.Match, nil
+	}
+
+	needle := []byte(pattern)
+	if ignoreCase {
+		needle = bytes.ToLower(needle)
+		return func(line []byte) bool { return bytes.Contains(bytes.ToLower(line), needle) }, nil
+	}
+	return func(line []byte) bool { return bytes.Contains(line, needle) }, nil
+}
+
+// grepFile 逐行搜索单个文件，没有匹配、无法读取或是二进制文件时返回 nil
+func grepFile(path string, match func(line []byte) bool) *GrepFile {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() || info.Size() > MaxGrepFileSize {
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(f, binarySniffSize)
+	if IsBinary(reader) {
+		return nil
+	}
+
+	var result GrepFile
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), MaxGrepFileSize)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Bytes()
+		if !match(line) {
+			continue
+		}
+		result.Count++
+		if len(result.Lines) < MaxGrepLines {
+			result.Lines = append(result.Lines, GrepLine{Number: number, Text: strings.TrimRight(string(line), "\r")})
+		}
+	}
+	if result.Count == 0 {
+		return nil
+	}
+	return &result
+}
+
+// IsBinary 根据开头是否包含 NUL 字节判断二进制文件 (不会消耗 reader 中的数据)
+// reader 的缓冲区需要不小于 8000 字节，否则只检查缓冲区内的部分
+func IsBinary(reader *bufio.Reader) bool {
+	head, err := reader.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return true
+	}
+	return bytes.IndexByte(head, 0) >= 0
+}
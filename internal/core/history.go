@@ -0,0 +1,378 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// MaxHistory 是撤销历史最多保留的条目数
+const MaxHistory = 100
+
+// 历史记录中的修改种类 (Change.Kind)
+const (
+	ChangeState  = "state"  // 节点状态 (注释、隐藏、折叠) 变化
+	ChangeMove   = "move"   // 重命名或移动：Path -> To
+	ChangeCreate = "create" // 新建文件或文件夹
+	ChangeCopy   = "copy"   // 复制：Path -> To
+	ChangeDelete = "delete" // 删除 (移到回收目录)
+	ChangeOrphan = "orphan" // 失效条目被丢弃或重新关联：Path 是条目的路径，Before 是它的状态
+)
+
+// Change 是一次可撤销的修改，路径都是相对扫描根目录的路径
+type Change struct {
+	Kind   string      `json:"kind"`
+	Path   string      `json:"path"`
+	To     string      `json:"to,omitempty"`
+	IsDir  bool        `json:"dir,omitempty"`
+	Before *NodeConfig `json:"before,omitempty"` // ChangeState 修改前后的状态
+	After  *NodeConfig `json:"after,omitempty"`
+
+	// 当前位于回收目录中的副本 (删除后，或新建/复制被撤销后)，用于恢复
+	Trash string `json:"trash,omitempty"`
+	// 被移入回收目录的子树的状态，键是相对被删除节点的路径 ("." 为节点自身)
+	Config map[string]NodeConfig `json:"config,omitempty"`
+}
+
+// HistoryEntry 是一次用户操作，可能包含多个修改 (例如批量隐藏)
+type HistoryEntry struct {
+	Label   string    `json:"label"`
+	Time    time.Time `json:"time"`
+	Changes []Change  `json:"changes"`
+}
+
+// History 是一个项目的撤销/重做历史，保存在用户缓存目录中，重启后仍可撤销
+type History struct {
+	Root string         `json:"root"`
+	Undo []HistoryEntry `json:"undo"`
+	Redo []HistoryEntry `json:"redo,omitempty"`
+}
+
+// LoadHistory 读取项目的历史记录，文件不存在或损坏时返回空历史
+func LoadHistory(rootPath string) *History {
+	h := &History{Root: rootPath}
+	path, err := historyPath(rootPath)
+	if err != nil {
+		return h
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+
+	var loaded History
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Root != rootPath {
+		return h
+	}
+	return &loaded
+}
+
+// Save 把历史记录写入用户缓存目录 (gentr/history/<根目录哈希>.json)
+func (h *History) Save() error {
+	path, err := historyPath(h.Root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// historyPath 返回根目录对应的历史文件路径
+func historyPath(rootPath string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rootPath))
+	return filepath.Join(cacheDir, "gentr", "history", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Record 记录一次操作，并清空重做栈；没有修改时忽略
+func (h *History) Record(label string, changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	h.Undo = append(h.Undo, HistoryEntry{Label: label, Time: time.Now(), Changes: changes})
+	if len(h.Undo) > MaxHistory {
+		h.Undo = h.Undo[len(h.Undo)-MaxHistory:]
+	}
+	h.Redo = nil
+}
+
+// UndoLast 撤销最近一次操作，返回被撤销的条目
+// 失败时 (例如文件已在外部被修改) 该条目被丢弃，避免卡住后续的撤销
+func (h *History) UndoLast(root *model.Node, state *ConfigState, opts WalkOptions) (*HistoryEntry, error) {
+	if len(h.Undo) == 0 {
+		return nil, nil
+	}
+	entry := h.Undo[len(h.Undo)-1]
+	h.Undo = h.Undo[:len(h.Undo)-1]
+
+	// 按相反的顺序撤销
+	for i := len(entry.Changes) - 1; i >= 0; i-- {
+		if err := revertChange(root, state, opts, &entry.Changes[i]); err != nil {
+			return &entry, err
+		}
+	}
+	h.Redo = append(h.Redo, entry)
+	return &entry, nil
+}
+
+// RedoLast 重做最近一次被撤销的操作
+func (h *History) RedoLast(root *model.Node, state *ConfigState, opts WalkOptions) (*HistoryEntry, error) {
+	if len(h.Redo) == 0 {
+		return nil, nil
+	}
+	entry := h.Redo[len(h.Redo)-1]
+	h.Redo = h.Redo[:len(h.Redo)-1]
+
+	for i := range entry.Changes {
+		if err := applyChange(root, state, opts, &entry.Changes[i]); err != nil {
+			return &entry, err
+		}
+	}
+	h.Undo = append(h.Undo, entry)
+	return &entry, nil
+}
+
+// revertChange 撤销单个修改
+func revertChange(root *model.Node, state *ConfigState, opts WalkOptions, c *Change) error {
+	switch c.Kind {
+	case ChangeState:
+		return setNodeState(root, c.Path, c.Before)
+	case ChangeMove:
+		return moveRel(root, state, c.To, c.Path)
+	case ChangeCreate:
+		return trashRel(root, state, c.Path, c)
+	case ChangeCopy:
+		return trashRel(root, state, c.To, c)
+	case ChangeDelete:
+		return restoreRel(root, opts, c.Path, c)
+	case ChangeOrphan:
+		return restoreOrphan(state, c)
+	}
+	return fmt.Errorf("unknown change %q", c.Kind)
+}
+
+// applyChange 重新执行单个修改
+func applyChange(root *model.Node, state *ConfigState, opts WalkOptions, c *Change) error {
+	switch c.Kind {
+	case ChangeState:
+		return setNodeState(root, c.Path, c.After)
+	case ChangeMove:
+		return moveRel(root, state, c.Path, c.To)
+	case ChangeCreate:
+		return restoreRel(root, opts, c.Path, c)
+	case ChangeCopy:
+		return restoreRel(root, opts, c.To, c)
+	case ChangeDelete:
+		return trashRel(root, state, c.Path, c)
+	case ChangeOrphan:
+		return RemoveOrphan(state, c.Path)
+	}
+	return fmt.Errorf("unknown change %q", c.Kind)
+}
+
+// HasFileChanges 判断条目是否修改了磁盘上的文件 (需要刷新 Git 状态)
+func (e *HistoryEntry) HasFileChanges() bool {
+	for _, c := range e.Changes {
+		if c.Kind != ChangeState && c.Kind != ChangeOrphan {
+			return true
+		}
+	}
+	return false
+}
+
+// OrphanChange 构造处理失效条目的修改，撤销时条目回到失效列表中
+func OrphanChange(orphan Orphan) Change {
+	conf := orphan.Config
+	return Change{Kind: ChangeOrphan, Path: orphan.Path, Before: &conf}
+}
+
+// RemoveOrphan 从失效列表中移除指定路径的条目
+func RemoveOrphan(state *ConfigState, relPath string) error {
+	if state != nil {
+		for i, orphan := range state.Orphans {
+			if orphan.Path == relPath {
+				state.Orphans = append(state.Orphans[:i:i], state.Orphans[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is no longer an orphaned entry", relPath)
+}
+
+// restoreOrphan 把条目放回失效列表 (按路径排序)
+func restoreOrphan(state *ConfigState, c *Change) error {
+	if state == nil || c.Before == nil {
+		return fmt.Errorf("cannot restore orphaned entry %s", c.Path)
+	}
+	i := sort.Search(len(state.Orphans), func(i int) bool { return state.Orphans[i].Path >= c.Path })
+	if i < len(state.Orphans) && state.Orphans[i].Path == c.Path {
+		return fmt.Errorf("%s is already an orphaned entry", c.Path)
+	}
+	state.Orphans = slices.Insert(state.Orphans, i, Orphan{Path: c.Path, Config: *c.Before})
+	return nil
+}
+
+// DeleteChange 在删除节点之前构造对应的修改，记录子树的状态以便恢复
+// 删除成功后由调用方填入 Trash
+func DeleteChange(root, node *model.Node) Change {
+	return Change{
+		Kind:   ChangeDelete,
+		Path:   NodeRelPath(root, node),
+		IsDir:  node.IsDir,
+		Config: SnapshotState(node),
+	}
+}
+
+// SnapshotState 收集子树中所有非默认的节点状态，键是相对 node 的路径 ("." 为 node 自身)
+// 与 SaveConfig 不同，不计算内容哈希，可以在每次修改前调用
+func SnapshotState(node *model.Node) map[string]NodeConfig {
+	snapshot := make(map[string]NodeConfig)
+	var walk func(n *model.Node)
+	walk = func(n *model.Node) {
+		if n.Ghost {
+			return
+		}
+		if n.Annotation != "" || n.Collapsed || n.Hidden {
+			if relPath, err := filepath.Rel(node.Path, n.Path); err == nil {
+				snapshot[filepath.ToSlash(relPath)] = NodeConfig{
+					Annotation: n.Annotation,
+					Collapsed:  n.Collapsed,
+					Hidden:     n.Hidden,
+				}
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(node)
+	return snapshot
+}
+
+// DiffState 比较根节点的两次快照，返回状态发生变化的节点
+func DiffState(before, after map[string]NodeConfig) []Change {
+	paths := make(map[string]bool)
+	for path := range before {
+		paths[path] = true
+	}
+	for path := range after {
+		paths[path] = true
+	}
+
+	var changes []Change
+	for path := range paths {
+		b, a := before[path], after[path]
+		if b == a {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeState, Path: path, Before: &b, After: &a})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// setNodeState 把状态写回指定路径的节点
+func setNodeState(root *model.Node, relPath string, conf *NodeConfig) error {
+	node, ok := indexNodes(root, root.Path)[relPath]
+	if !ok || conf == nil {
+		return fmt.Errorf("%s is no longer in the tree", relPath)
+	}
+	applyNodeConfig(node, *conf)
+	return nil
+}
+
+// moveRel 移动指定路径的节点，并迁移不在树中的配置条目
+func moveRel(root *model.Node, state *ConfigState, from, to string) error {
+	node, ok := indexNodes(root, root.Path)[from]
+	if !ok {
+		return fmt.Errorf("%s is no longer in the tree", from)
+	}
+	if err := MovePath(root, node, to); err != nil {
+		return err
+	}
+	MigrateConfig(state, from, to)
+	return nil
+}
+
+// trashRel 把指定路径的节点移到回收目录，并把回收目录中的位置与子树状态记录到修改中
+func trashRel(root *model.Node, state *ConfigState, relPath string, c *Change) error {
+	node, ok := indexNodes(root, root.Path)[relPath]
+	if !ok {
+		return fmt.Errorf("%s is no longer in the tree", relPath)
+	}
+	config := SnapshotState(node)
+	trash, err := TrashPath(root, node)
+	if err != nil {
+		return err
+	}
+	MigrateConfig(state, relPath, "")
+	c.Trash, c.Config = trash, config
+	return nil
+}
+
+// restoreRel 把回收目录中的副本恢复到指定路径，并恢复子树的状态
+func restoreRel(root *model.Node, opts WalkOptions, relPath string, c *Change) error {
+	if c.Trash == "" {
+		return fmt.Errorf("no copy of %s in the trash", relPath)
+	}
+	node, err := RestorePath(root, c.Trash, relPath, opts)
+	if err != nil {
+		return err
+	}
+
+	nodes := indexNodes(node, node.Path)
+	for rel, conf := range c.Config {
+		if n, ok := nodes[rel]; ok {
+			applyNodeConfig(n, conf)
+		}
+	}
+	c.Trash, c.Config = "", nil
+	return nil
+}
+
+// RestorePath 把回收目录中的文件移回 relPath，并扫描出对应的子树插入树中
+func RestorePath(root *model.Node, trash, relPath string, opts WalkOptions) (*model.Node, error) {
+	abs, relPath, err := resolveRel(root.Path, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		return nil, fmt.Errorf("%s already exists", relPath)
+	}
+	if _, err := os.Lstat(trash); err != nil {
+		return nil, fmt.Errorf("%s is no longer in the trash", relPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, err
+	}
+	if err := movePath(trash, abs); err != nil {
+		return nil, err
+	}
+	os.Remove(filepath.Dir(trash)) // 回收目录中为本次删除创建的子文件夹，已经空了
+
+	node, _, err := Walk(context.Background(), abs, opts)
+	if err != nil {
+		return nil, err
+	}
+	attachNode(ensureDirNodes(root, filepath.Dir(abs)), node)
+	return node, nil
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	state := &ConfigState{
+		Unseen: map[string]NodeConfig{
+			"src/big.bin":   {Annotation: "big"},
+			"srcx/other.go": {Annotation: "other"},
+		},
+		Folded: map[string]bool{"src": true, "src/gen": true},
+		Orphans: []Orphan{
+			{Path: "lib/z.go"},
+			{Path: "src/gone.go", Config: NodeConfig{Annotation: "gone"}},
+		},
+	}
+
+	MigrateConfig(state, "src", "app/src")
+	wantUnseen := map[string]NodeConfig{
+		"app/src/big.bin": {Annotation: "big"},
+		"srcx/other.go":   {Annotation: "other"},
+	}
+	if !reflect.DeepEqual(state.Unseen, wantUnseen) {
+		t.Errorf("Unseen = %v, want %v", state.Unseen, wantUnseen)
+	}
+	if want := map[string]bool{"app/src": true, "app/src/gen": true}; !reflect.DeepEqual(state.Folded, want) {
+		t.Errorf("Folded = %v, want %v", state.Folded, want)
+	}
+	wantOrphans := []Orphan{
+		{Path: "app/src/gone.go", Config: NodeConfig{Annotation: "gone"}},
+		{Path: "lib/z.go"},
+	}
+	if !reflect.DeepEqual(state.Orphans, wantOrphans) {
+		t.Errorf("Orphans = %v, want %v", state.Orphans, wantOrphans)
+	}
+
+	// 删除时丢弃未出现的条目与折叠状态，失效条目保留
+	MigrateConfig(state, "app", "")
+	if len(state.Unseen) != 1 || len(state.Folded) != 0 || len(state.Orphans) != 2 {
+		t.Errorf("after delete: Unseen = %v, Folded = %v, Orphans = %v", state.Unseen, state.Folded, state.Orphans)
+	}
+}
+
+func TestOrphanUndo(t *testing.T) {
+	root := &model.Node{Name: "root", Path: "/p", IsDir: true}
+	target := &model.Node{Name: "new.go", Path: "/p/new.go"}
+	root.Children = []*model.Node{target}
+
+	gone := Orphan{Path: "old.go", Config: NodeConfig{Annotation: "moved"}}
+	state := &ConfigState{Orphans: []Orphan{{Path: "a.go"}, gone, {Path: "z.go"}}}
+	h := &History{Root: root.Path}
+
+	// 重新关联：节点状态变化与失效条目一起记录
+	before := SnapshotState(root)
+	if err := AttachOrphan(root.Path, root, gone, "new.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveOrphan(state, gone.Path); err != nil {
+		t.Fatal(err)
+	}
+	h.Record("Re-attach", append(DiffState(before, SnapshotState(root)), OrphanChange(gone)))
+
+	if _, err := h.UndoLast(root, state, WalkOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if target.Annotation != "" {
+		t.Errorf("undo left annotation %q on the target", target.Annotation)
+	}
+	if want := []Orphan{{Path: "a.go"}, gone, {Path: "z.go"}}; !reflect.DeepEqual(state.Orphans, want) {
+		t.Errorf("Orphans after undo = %v, want %v", state.Orphans, want)
+	}
+
+	if _, err := h.RedoLast(root, state, WalkOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if target.Annotation != "moved" || len(state.Orphans) != 2 {
+		t.Errorf("after redo: annotation = %q, Orphans = %v", target.Annotation, state.Orphans)
+	}
+	if h.Undo[0].HasFileChanges() {
+		t.Error("orphan changes should not count as file changes")
+	}
+}
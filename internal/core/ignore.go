@@ -0,0 +1,295 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule 是一条编译后的忽略规则
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // 以 "!" 开头，重新包含之前被忽略的路径
+	dirOnly bool // 以 "/" 结尾，只匹配文件夹
+}
+
+// ignoreLayer 是来自同一个文件的一组规则
+// base 是规则的生效目录 (相对仓库根目录，"/" 分隔，"" 表示仓库根目录)
+type ignoreLayer struct {
+	base  string
+	rules []ignoreRule
+}
+
+// IgnoreMatcher 按 git 的优先级叠加多层忽略规则
+// 优先级从低到高: core.excludesFile < .git/info/exclude < 根 .gitignore < 子目录 .gitignore
+// 同一层内后出现的规则覆盖先出现的规则
+type IgnoreMatcher struct {
+	layers      []*ignoreLayer
+	prefix      string // 扫描目录相对仓库根目录的路径，不在仓库中或扫描仓库根目录时为 ""
+	noGitignore bool   // 只使用配置中的规则，不读取子目录中的 .gitignore
+}
+
+// NewIgnoreMatcher 为扫描目录加载全局忽略文件、info/exclude 和 .gitignore
+// 扫描的是仓库的子目录时，仓库根目录到扫描目录之间所有祖先目录的 .gitignore 同样生效
+// patterns 是配置中的额外规则，相对扫描目录生效，优先级最低 (.gitignore 中的 "!" 可以重新包含)
+func NewIgnoreMatcher(rootPath string, patterns []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+
+	repo, repoErr := FindGitRepo(rootPath)
+	if repoErr == nil {
+		m.prefix = repo.Prefix
+	}
+	m = m.withRules(parseIgnoreLines(strings.Join(patterns, "\n")), m.prefix)
+
+	if path := globalExcludesFile(rootPath); path != "" {
+		m = m.withFile(path, "")
+	}
+	m = m.withFile(gitPath(rootPath, "info/exclude"), "")
+
+	if repoErr == nil {
+		for _, dir := range repo.ancestorDirs() {
+			m = m.withFile(filepath.Join(dir[0], ".gitignore"), dir[1])
+		}
+	}
+	return m.withFile(filepath.Join(rootPath, ".gitignore"), m.prefix)
+}
+
+// NewPatternMatcher 只包含配置中的忽略规则 (无视 .gitignore 时使用)，规则相对扫描目录生效
+func NewPatternMatcher(rootPath string, patterns []string) *IgnoreMatcher {
+	return (&IgnoreMatcher{noGitignore: true}).withRules(parseIgnoreLines(strings.Join(patterns, "\n")), "")
+}
+
+// Child 返回进入子目录后的匹配器：如果子目录下存在 .gitignore 则叠加一层
+// dirPath 是子目录的绝对路径，relDir 是其相对扫描目录的路径
+func (m *IgnoreMatcher) Child(dirPath, relDir string) *IgnoreMatcher {
+	if m == nil || m.noGitignore {
+		return m
+	}
+	return m.withFile(filepath.Join(dirPath, ".gitignore"), m.repoPath(relDir))
+}
+
+// Match 判断相对扫描目录的路径是否被忽略
+// 从最高优先级的规则开始倒序检查，第一条匹配的规则决定结果
+func (m *IgnoreMatcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	relPath = m.repoPath(filepath.ToSlash(relPath))
+
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		layer := m.layers[i]
+
+		// 规则只对其所在目录下的路径生效，匹配时使用相对该目录的路径
+		target := relPath
+		if layer.base != "" {
+			if !strings.HasPrefix(relPath, layer.base+"/") {
+				continue
+			}
+			target = relPath[len(layer.base)+1:]
+		}
+
+		for j := len(layer.rules) - 1; j >= 0; j-- {
+			rule := layer.rules[j]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.pattern.MatchString(target) {
+				return !rule.negate
+			}
+		}
+	}
+	return false
+}
+
+// withFile 读取忽略文件并返回叠加了新层的匹配器
+// 文件不存在或没有有效规则时返回原匹配器，层切片按需复制，避免兄弟目录之间互相污染
+func (m *IgnoreMatcher) withFile(path, base string) *IgnoreMatcher {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m
+	}
+
+	return m.withRules(parseIgnoreLines(string(data)), base)
+}
+
+// withRules 返回叠加了一层规则的匹配器，没有规则时返回原匹配器
+func (m *IgnoreMatcher) withRules(rules []ignoreRule, base string) *IgnoreMatcher {
+	if len(rules) == 0 {
+		return m
+	}
+
+	layers := make([]*ignoreLayer, len(m.layers), len(m.layers)+1)
+	copy(layers, m.layers)
+	layers = append(layers, &ignoreLayer{base: base, rules: rules})
+	return &IgnoreMatcher{layers: layers, prefix: m.prefix, noGitignore: m.noGitignore}
+}
+
+// repoPath 将相对扫描目录的路径转换为相对仓库根目录的路径
+func (m *IgnoreMatcher) repoPath(relPath string) string {
+	if m.prefix == "" {
+		return relPath
+	}
+	return m.prefix + "/" + relPath
+}
+
+// parseIgnoreLines 按 gitignore 语法解析规则
+func parseIgnoreLines(content string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		if rule, ok := compileIgnoreRule(line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// compileIgnoreRule 将一行 gitignore 规则编译为正则表达式
+func compileIgnoreRule(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimRight(line, "\r")
+
+	// 空行和注释 (以 "\#" 开头的是字面量 "#")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	// 去掉行尾未转义的空格
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// 开头或中间包含 "/" 的规则锚定在所在目录，否则可以匹配任意层级
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = re
+	return rule, true
+}
+
+// globToRegexp 将 gitignore 的通配符转换为正则表达式
+// 支持 "*"、"?"、"[...]"、"\" 转义以及 "**" 的三种形式 (前缀、中缀、后缀)
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	runes := []rune(glob)
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				atStart := i == 0 || runes[i-1] == '/'
+				atEnd := i+2 == len(runes)
+				if atStart && atEnd {
+					// "**" 或 ".../**": 匹配其下的所有内容
+					sb.WriteString(".*")
+					i++
+					continue
+				}
+				if atStart && runes[i+2] == '/' {
+					// "**/": 匹配零个或多个目录
+					sb.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			// 查找字符组的结尾，找不到就当作普通字符
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := runes[i+1 : end]
+			sb.WriteString("[")
+			if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+				sb.WriteString("^")
+				class = class[1:]
+			}
+			sb.WriteString(strings.ReplaceAll(string(class), `\`, `\\`))
+			sb.WriteString("]")
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// globalExcludesFile 返回 core.excludesFile 指向的文件
+// 未配置时回落到 git 的默认位置 $XDG_CONFIG_HOME/git/ignore
+func globalExcludesFile(rootPath string) string {
+	cmd := exec.Command("git", "config", "--path", "core.excludesFile")
+	cmd.Dir = rootPath
+	if output, err := cmd.Output(); err == nil {
+		if path := string(bytes.TrimSpace(output)); path != "" {
+			return path
+		}
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// gitPath 解析 .git 目录下文件的真实路径 (兼容 worktree 与 .git 文件)
+func gitPath(rootPath, name string) string {
+	cmd := exec.Command("git", "rev-parse", "--git-path", name)
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return filepath.Join(rootPath, ".git", filepath.FromSlash(name))
+	}
+
+	path := string(bytes.TrimSpace(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(rootPath, path)
+	}
+	return path
+}
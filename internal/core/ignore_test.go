@@ -0,0 +1,148 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// patternMatcher 用给定的规则创建不读取任何文件的匹配器
+func patternMatcher(lines ...string) *IgnoreMatcher {
+	return (&IgnoreMatcher{}).withRules(parseIgnoreLines(strings.Join(lines, "\n")), "")
+}
+
+func TestIgnoreMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// 不带 "/" 的规则匹配任意层级
+		{"unanchored top level", []string{"*.log"}, "a.log", false, true},
+		{"unanchored nested", []string{"*.log"}, "src/deep/a.log", false, true},
+		{"unanchored no match", []string{"*.log"}, "a.txt", false, false},
+		{"star does not cross slash", []string{"a*c"}, "ab/c", false, false},
+		{"question mark", []string{"?.go"}, "x.go", false, true},
+		{"char class", []string{"[ab].txt"}, "b.txt", false, true},
+		{"negated char class", []string{"[!ab].txt"}, "a.txt", false, false},
+
+		// 开头或中间带 "/" 的规则锚定在所在目录
+		{"leading slash anchored", []string{"/build"}, "build", true, true},
+		{"leading slash not nested", []string{"/build"}, "src/build", true, false},
+		{"middle slash anchored", []string{"docs/*.tmp"}, "docs/a.tmp", false, true},
+		{"middle slash not nested", []string{"docs/*.tmp"}, "x/docs/a.tmp", false, false},
+		{"middle slash star stays in dir", []string{"docs/*.tmp"}, "docs/sub/a.tmp", false, false},
+
+		// 以 "/" 结尾的规则只匹配文件夹
+		{"dir rule matches dir", []string{"out/"}, "out", true, true},
+		{"dir rule skips file", []string{"out/"}, "out", false, false},
+		{"dir rule nested", []string{"out/"}, "pkg/out", true, true},
+		{"anchored dir rule", []string{"/out/"}, "pkg/out", true, false},
+
+		// "**" 的前缀、中缀、后缀形式
+		{"double star prefix top", []string{"**/foo"}, "foo", false, true},
+		{"double star prefix deep", []string{"**/foo"}, "a/b/foo", false, true},
+		{"double star prefix with dir", []string{"**/lib/x"}, "a/lib/x", false, true},
+		{"double star middle zero dirs", []string{"a/**/b"}, "a/b", false, true},
+		{"double star middle many dirs", []string{"a/**/b"}, "a/x/y/b", false, true},
+		{"double star middle anchored", []string{"a/**/b"}, "x/a/b", false, false},
+		{"double star suffix", []string{"abc/**"}, "abc/x/y", false, true},
+		{"double star suffix not dir itself", []string{"abc/**"}, "abc", true, false},
+
+		// "!" 重新包含，后出现的规则优先
+		{"negation re-includes", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"negation leaves others", []string{"*.log", "!keep.log"}, "drop.log", false, true},
+		{"later rule wins", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+
+		// 注释、转义与空白
+		{"comment line", []string{"#secret"}, "#secret", false, false},
+		{"escaped hash", []string{`\#secret`}, "#secret", false, true},
+		{"escaped bang", []string{`\!important`}, "!important", false, true},
+		{"escaped bang is not negation", []string{"*", `\!important`}, "!important", false, true},
+		{"trailing spaces trimmed", []string{"foo   "}, "foo", false, true},
+		{"escaped trailing space kept", []string{`foo\ `}, "foo ", false, true},
+		{"blank line", []string{"", "   "}, "x", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := patternMatcher(tt.rules...)
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("rules %q: Match(%q, dir=%v) = %v, want %v", tt.rules, tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreNestedLayers(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"sub", "sub/deep", "other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 子目录重新包含根规则忽略的文件，并追加自己的规则
+	writeFile(t, filepath.Join(root, "sub", ".gitignore"), "!keep.txt\n/local.md\n")
+	writeFile(t, filepath.Join(root, "sub", "deep", ".gitignore"), "keep.txt\n")
+
+	rootMatcher := patternMatcher("*.txt")
+	sub := rootMatcher.Child(filepath.Join(root, "sub"), "sub")
+	deep := sub.Child(filepath.Join(root, "sub", "deep"), "sub/deep")
+	other := rootMatcher.Child(filepath.Join(root, "other"), "other")
+
+	tests := []struct {
+		name    string
+		matcher *IgnoreMatcher
+		path    string
+		want    bool
+	}{
+		{"root rule at root", rootMatcher, "keep.txt", true},
+		{"child re-includes", sub, "sub/keep.txt", false},
+		{"child keeps other root rules", sub, "sub/other.txt", true},
+		{"child re-include is scoped to child", sub, "keep.txt", true},
+		{"child anchored rule is relative to child", sub, "sub/local.md", true},
+		{"child anchored rule not deeper", sub, "sub/x/local.md", false},
+		{"grandchild overrides child", deep, "sub/deep/keep.txt", true},
+		{"sibling not affected", other, "other/keep.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.Match(tt.path, false); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreRepoPrefix(t *testing.T) {
+	// 扫描仓库子目录时，仓库根目录的锚定规则按仓库路径匹配
+	m := (&IgnoreMatcher{prefix: "pkg"}).withRules(parseIgnoreLines("/pkg/gen\n/gen"), "")
+	if !m.Match("gen", true) {
+		t.Error("/pkg/gen should match gen when scanning pkg/")
+	}
+	m = (&IgnoreMatcher{prefix: "pkg"}).withRules(parseIgnoreLines("/gen"), "")
+	if m.Match("gen", true) {
+		t.Error("/gen from the repo root should not match pkg/gen")
+	}
+}
+
+func TestNilIgnoreMatcher(t *testing.T) {
+	var m *IgnoreMatcher
+	if m.Match("anything", false) {
+		t.Error("nil matcher should not ignore anything")
+	}
+	if m.Child("/tmp", "x") != nil {
+		t.Error("Child of nil matcher should stay nil")
+	}
+}
+
+// writeFile 写入测试文件，失败时终止测试
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// maxRenameCommits 限制读取重命名历史的提交数，避免在大仓库中拖慢启动
+const maxRenameCommits = 1000
+
+// reattachOrphans 尝试为路径失效的条目找到新位置
+// 依次使用: 工作区中的重命名 (git status)、历史提交中的重命名 (git log)、文件内容哈希
+// 已经有配置的节点不会被覆盖，返回仍未关联的条目与成功关联的数量
+func reattachOrphans(rootPath string, nodes map[string]*model.Node, configured map[string]NodeConfig, orphans []Orphan) ([]Orphan, int) {
+	if len(orphans) == 0 {
+		return nil, 0
+	}
+
+	// 目标节点必须空闲：在配置中没有条目，也没有被其它失效条目占用
+	taken := make(map[string]bool, len(configured))
+	for relPath := range configured {
+		if _, ok := nodes[relPath]; ok {
+			taken[relPath] = true
+		}
+	}
+	attach := func(orphan Orphan, relPath string) bool {
+		node, ok := nodes[relPath]
+		if !ok || taken[relPath] {
+			return false
+		}
+		applyNodeConfig(node, orphan.Config)
+		taken[relPath] = true
+		return true
+	}
+
+	// 1. 工作区中尚未提交的重命名
+	renames := make(map[string]string)
+	for relPath, node := range nodes {
+		if node.GitOrigPath != "" {
+			renames[node.GitOrigPath] = relPath
+		}
+	}
+	remaining, count := reattachByRenames(orphans, renames, nodes, attach)
+
+	// 2. 历史提交中的重命名 (git mv 之后已经提交)
+	// git log 比较慢：HEAD 没有变化且没有新的失效条目时，上次已经查过，直接跳过
+	scannedHead := ""
+	if len(remaining) > 0 {
+		if head := gitHead(rootPath); head != "" && !renameScanDone(rootPath, head, remaining) {
+			scannedHead = head
+		}
+	}
+	if scannedHead != "" {
+		if history, err := LoadGitRenames(rootPath); err == nil && len(history) > 0 {
+			for oldPath, newPath := range history {
+				if _, ok := renames[oldPath]; !ok {
+					renames[oldPath] = newPath
+				}
+			}
+			var n int
+			remaining, n = reattachByRenames(remaining, renames, nodes, attach)
+			count += n
+		}
+	}
+
+	// 3. 内容哈希 (没有经过 git 的移动，或重命名检测失败)
+	if len(remaining) > 0 {
+		var n int
+		remaining, n = reattachByHash(remaining, nodes, taken, attach)
+		count += n
+	}
+
+	if scannedHead != "" {
+		saveRenameScan(rootPath, scannedHead, remaining)
+	}
+	return remaining, count
+}
+
+// reattachByRenames 沿着重命名链查找条目的新路径
+// 文件夹本身不会出现在重命名记录中，通过其子孙的重命名推断文件夹的新路径
+func reattachByRenames(orphans []Orphan, renames map[string]string, nodes map[string]*model.Node, attach func(Orphan, string) bool) ([]Orphan, int) {
+	var remaining []Orphan
+	count := 0
+	for _, orphan := range orphans {
+		newPath := followRenames(orphan.Path, renames)
+		if newPath == "" {
+			newPath = followDirRenames(orphan.Path, renames, nodes)
+		}
+		if newPath != "" && attach(orphan, newPath) {
+			count++
+			continue
+		}
+		remaining = append(remaining, orphan)
+	}
+	return remaining, count
+}
+
+// followRenames 沿着 旧路径 -> 新路径 的链条前进，返回最终路径；没有重命名记录时返回 ""
+func followRenames(relPath string, renames map[string]string) string {
+	current := relPath
+	// 链条长度不会超过记录数，防止 a -> b -> a 这样的环
+	for i := 0; i <= len(renames); i++ {
+		next, ok := renames[current]
+		if !ok {
+			break
+		}
+		current = next
+	}
+	if current == relPath {
+		return ""
+	}
+	return current
+}
+
+// followDirRenames 根据子孙文件的重命名推断文件夹的新路径
+// 例如 "a/x.go -> b/x.go" 说明文件夹 "a" 被移动到了 "b"
+func followDirRenames(dirPath string, renames map[string]string, nodes map[string]*model.Node) string {
+	for oldPath, newPath := range renames {
+		suffix, ok := strings.CutPrefix(oldPath, dirPath+"/")
+		if !ok {
+			continue
+		}
+		newDir, ok := strings.CutSuffix(newPath, "/"+suffix)
+		if !ok || newDir == dirPath {
+			continue
+		}
+		if node, ok := nodes[newDir]; ok && node.IsDir {
+			return newDir
+		}
+	}
+	return ""
+}
+
+// reattachByHash 在同名或同扩展名的空闲文件中查找内容哈希相同的文件
+func reattachByHash(orphans []Orphan, nodes map[string]*model.Node, taken map[string]bool, attach func(Orphan, string) bool) ([]Orphan, int) {
+	var remaining []Orphan
+	count := 0
+	hashes := make(map[string]string) // 缓存已计算的哈希，多个条目共享候选文件
+	for _, orphan := range orphans {
+		if orphan.Config.Hash == "" {
+			remaining = append(remaining, orphan)
+			continue
+		}
+
+		found := ""
+		name, ext := path.Base(orphan.Path), path.Ext(orphan.Path)
+		for relPath, node := range nodes {
+			if node.IsDir || taken[relPath] {
+				continue
+			}
+			if node.Name != name && (ext == "" || filepath.Ext(node.Name) != ext) {
+				continue
+			}
+			hash, ok := hashes[relPath]
+			if !ok {
+				hash, _ = hashFile(node.Path)
+				hashes[relPath] = hash
+			}
+			// 同名文件优先，其次按路径排序，保证结果稳定
+			if hash == orphan.Config.Hash && (found == "" || betterCandidate(relPath, found, name)) {
+				found = relPath
+			}
+		}
+
+		if found != "" && attach(orphan, found) {
+			count++
+			continue
+		}
+		remaining = append(remaining, orphan)
+	}
+	return remaining, count
+}
+
+// betterCandidate 判断候选路径 a 是否比 b 更适合作为名为 name 的文件的新位置
+func betterCandidate(a, b, name string) bool {
+	aSame, bSame := path.Base(a) == name, path.Base(b) == name
+	if aSame != bSame {
+		return aSame
+	}
+	return a < b
+}
+
+// hashFile 计算文件内容的 SHA-256
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadGitRenames 读取最近提交中的重命名记录 (git log -M --diff-filter=R)
+// 返回 旧路径 -> 新路径，路径相对扫描目录；同一路径被多次重命名时保留最近的一次
+func LoadGitRenames(rootPath string) (map[string]string, error) {
+	repo, err := FindGitRepo(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "log", "-M", "--diff-filter=R", "--name-status", "-z", "--format=",
+		fmt.Sprintf("--max-count=%d", maxRenameCommits))
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError("git log", err)
+	}
+
+	// 输出格式: "R100\0旧路径\0新路径\0"，按提交从新到旧排列
+	renames := make(map[string]string)
+	fields := bytes.Split(output, []byte{0})
+	for i := 0; i+2 < len(fields); i++ {
+		status := strings.TrimSpace(string(fields[i]))
+		if !strings.HasPrefix(status, "R") {
+			continue
+		}
+		oldPath, oldOK := repo.RelPath(string(fields[i+1]))
+		newPath, newOK := repo.RelPath(string(fields[i+2]))
+		i += 2
+		if !oldOK || !newOK {
+			continue
+		}
+		if _, ok := renames[oldPath]; !ok {
+			renames[oldPath] = newPath
+		}
+	}
+	return renames, nil
+}
+
+// renameScan 记录上一次读取重命名历史时的 HEAD 与之后仍未关联的条目
+// 保存在用户缓存目录中 (gentr/renames/<根目录哈希>.json)
+type renameScan struct {
+	Root    string   `json:"root"`
+	Head    string   `json:"head"`
+	Orphans []string `json:"orphans"`
+}
+
+// gitHead 返回扫描目录所在仓库的 HEAD 提交，不是仓库或没有提交时返回空
+func gitHead(rootPath string) string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "-q", "HEAD")
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// renameScanDone 判断在 head 上是否已经为所有这些条目读取过重命名历史
+func renameScanDone(rootPath, head string, orphans []Orphan) bool {
+	path, err := renameScanPath(rootPath)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var scan renameScan
+	if json.Unmarshal(data, &scan) != nil || scan.Root != rootPath || scan.Head != head {
+		return false
+	}
+
+	checked := make(map[string]bool, len(scan.Orphans))
+	for _, p := range scan.Orphans {
+		checked[p] = true
+	}
+	for _, orphan := range orphans {
+		if !checked[orphan.Path] {
+			return false
+		}
+	}
+	return true
+}
+
+// saveRenameScan 记录本次读取重命名历史的结果，写入失败时下次重新读取
+func saveRenameScan(rootPath, head string, orphans []Orphan) {
+	path, err := renameScanPath(rootPath)
+	if err != nil {
+		return
+	}
+	scan := renameScan{Root: rootPath, Head: head, Orphans: []string{}}
+	for _, orphan := range orphans {
+		scan.Orphans = append(scan.Orphans, orphan.Path)
+	}
+	data, err := json.Marshal(scan)
+	if err != nil || os.MkdirAll(filepath.Dir(path), 0700) != nil {
+		return
+	}
+	writeFileAtomic(path, data, 0600)
+}
+
+// renameScanPath 返回根目录对应的记录文件路径
+func renameScanPath(rootPath string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rootPath))
+	return filepath.Join(cacheDir, "gentr", "renames", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// AttachOrphan 把失效条目手动关联到 relPath 对应的节点上
+func AttachOrphan(rootPath string, root *model.Node, orphan Orphan, relPath string) error {
+	relPath = strings.Trim(filepath.ToSlash(relPath), "/")
+	if relPath == "" {
+		relPath = "."
+	}
+
+	node, ok := indexNodes(root, rootPath)[relPath]
+	if !ok {
+		return fmt.Errorf("%s: not found in tree", relPath)
+	}
+	if node.Annotation != "" {
+		return fmt.Errorf("%s already has a comment", relPath)
+	}
+	applyNodeConfig(node, orphan.Config)
+	return nil
+}
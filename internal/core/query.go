@@ -0,0 +1,451 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// 查询语法 (搜索框与 gentr export --query 共用):
+//
+//	main            模糊匹配相对路径 (fzf 风格)
+//	ext:go,md       扩展名
+//	name:*_test.go  文件名通配符
+//	path:cmd/**     相对路径通配符，匹配文件夹时包含其下所有内容
+//	re:_test\.go$   相对路径正则表达式
+//	git:M           Git 状态 (M A D R C T U !)，也可以是 changed、staged、ignored
+//	ann:"todo"      注释包含的文字，ann:* 表示有注释
+//	type:dir        节点类型 (file 或 dir)
+//
+// 多个条件之间默认为 AND，支持 AND、OR、NOT (或前缀 "-") 以及括号，
+// 包含空格的值使用双引号，例如 ann:"entry point"
+
+// Query 是解析后的搜索条件
+type Query struct {
+	root  queryExpr
+	fuzzy []string // 不在 NOT 之下的模糊匹配词，用于打分与高亮
+}
+
+// queryExpr 是查询语法树的节点
+type queryExpr interface {
+	match(node *model.Node, relPath string) bool
+}
+
+type andExpr struct{ left, right queryExpr }
+type orExpr struct{ left, right queryExpr }
+type notExpr struct{ expr queryExpr }
+
+// fuzzyExpr 是不带字段的词，模糊匹配相对路径
+type fuzzyExpr struct{ pattern string }
+
+// predicateExpr 是 "字段:值" 编译后的判断函数
+type predicateExpr func(node *model.Node, relPath string) bool
+
+func (e andExpr) match(node *model.Node, relPath string) bool {
+	return e.left.match(node, relPath) && e.right.match(node, relPath)
+}
+
+func (e orExpr) match(node *model.Node, relPath string) bool {
+	return e.left.match(node, relPath) || e.right.match(node, relPath)
+}
+
+func (e notExpr) match(node *model.Node, relPath string) bool {
+	return !e.expr.match(node, relPath)
+}
+
+func (e fuzzyExpr) match(_ *model.Node, relPath string) bool {
+	_, ok := FuzzyMatch(e.pattern, relPath)
+	return ok
+}
+
+func (e predicateExpr) match(node *model.Node, relPath string) bool {
+	return e(node, relPath)
+}
+
+// ParseQuery 解析搜索条件，语法错误时返回说明错误原因的 error
+// 空查询返回 nil，nil 查询匹配所有节点
+func ParseQuery(input string) (*Query, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		// 唯一可能剩下的是多余的右括号
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Query{root: root, fuzzy: p.fuzzy}, nil
+}
+
+// Match 判断节点本身是否满足条件，relPath 是相对扫描根目录的路径 ("/" 分隔)
+func (q *Query) Match(node *model.Node, relPath string) bool {
+	if q == nil {
+		return true
+	}
+	return q.root.match(node, relPath)
+}
+
+// Score 为满足条件的节点打分并给出高亮位置，只考虑模糊匹配词
+// 多个词命中同一位置时只高亮一次
+func (q *Query) Score(relPath string) FuzzyResult {
+	var result FuzzyResult
+	if q == nil {
+		return result
+	}
+
+	seen := make(map[int]bool)
+	for _, pattern := range q.fuzzy {
+		r, ok := FuzzyMatch(pattern, relPath)
+		if !ok {
+			continue
+		}
+		result.Score += r.Score
+		for _, pos := range r.Positions {
+			if !seen[pos] {
+				seen[pos] = true
+				result.Positions = append(result.Positions, pos)
+			}
+		}
+	}
+	sort.Ints(result.Positions)
+	return result
+}
+
+// Visible 返回在查询条件下应当显示的节点：自身或任一子孙满足条件
+func (q *Query) Visible(rootPath string, root *model.Node) map[*model.Node]bool {
+	visible := make(map[*model.Node]bool)
+	var walk func(node *model.Node) bool
+	walk = func(node *model.Node) bool {
+		shown := false
+		if relPath, err := filepath.Rel(rootPath, node.Path); err == nil && q.Match(node, filepath.ToSlash(relPath)) {
+			shown = true
+		}
+		for _, child := range node.Children {
+			if walk(child) {
+				shown = true
+			}
+		}
+		if shown {
+			visible[node] = true
+		}
+		return shown
+	}
+	for _, child := range root.Children {
+		walk(child)
+	}
+	return visible
+}
+
+// queryToken 是词法分析的结果
+type queryToken struct {
+	text   string
+	field  string // "ext:go" 中的 "ext"，没有字段时为空
+	quoted bool   // 含引号的词不会被当作 AND/OR/NOT 关键字
+	paren  bool   // "(" 或 ")"
+}
+
+// tokenizeQuery 按空白与括号切分查询，处理双引号 (支持 \" 转义)
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: string(c), paren: true})
+			i++
+			continue
+		}
+
+		var tok queryToken
+		var sb strings.Builder
+		for i < len(runes) && runes[i] != ' ' && runes[i] != '\t' && runes[i] != '(' && runes[i] != ')' {
+			switch r := runes[i]; {
+			case r == '"':
+				tok.quoted = true
+				i++
+				closed := false
+				for i < len(runes) {
+					if runes[i] == '\\' && i+1 < len(runes) {
+						sb.WriteRune(runes[i+1])
+						i += 2
+						continue
+					}
+					if runes[i] == '"' {
+						closed = true
+						i++
+						break
+					}
+					sb.WriteRune(runes[i])
+					i++
+				}
+				if !closed {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+			case r == ':' && tok.field == "" && !tok.quoted && sb.Len() > 0:
+				// 第一个冒号之前的部分是字段名
+				tok.field = sb.String()
+				sb.Reset()
+				i++
+			default:
+				sb.WriteRune(r)
+				i++
+			}
+		}
+		tok.text = sb.String()
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// queryParser 是递归下降解析器
+// 优先级从低到高: OR < AND (可省略) < NOT < 括号/条件
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	fuzzy  []string
+}
+
+// keyword 判断当前位置是否是指定的关键字 (必须大写且没有引号)
+func (p *queryParser) keyword(word string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	tok := p.tokens[p.pos]
+	return !tok.quoted && !tok.paren && tok.field == "" && tok.text == word
+}
+
+func (p *queryParser) parseOr(negated bool) (queryExpr, error) {
+	left, err := p.parseAnd(negated)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.parseAnd(negated)
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd(negated bool) (queryExpr, error) {
+	left, err := p.parseNot(negated)
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		if p.keyword("OR") || (tok.paren && tok.text == ")") {
+			break
+		}
+		if p.keyword("AND") {
+			p.pos++
+		}
+		right, err := p.parseNot(negated)
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot(negated bool) (queryExpr, error) {
+	if p.keyword("NOT") {
+		p.pos++
+		expr, err := p.parseNot(!negated)
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+
+	// "-term" 是 NOT term 的简写
+	if p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		if !tok.quoted && !tok.paren && tok.field == "" && len(tok.text) > 1 && tok.text[0] == '-' {
+			p.tokens[p.pos].text = tok.text[1:]
+			expr, err := p.parsePrimary(!negated)
+			if err != nil {
+				return nil, err
+			}
+			return notExpr{expr}, nil
+		}
+		if !tok.quoted && !tok.paren && len(tok.field) > 1 && tok.field[0] == '-' {
+			p.tokens[p.pos].field = tok.field[1:]
+			expr, err := p.parsePrimary(!negated)
+			if err != nil {
+				return nil, err
+			}
+			return notExpr{expr}, nil
+		}
+	}
+	return p.parsePrimary(negated)
+}
+
+func (p *queryParser) parsePrimary(negated bool) (queryExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("missing search term at end of query")
+	}
+	tok := p.tokens[p.pos]
+
+	if tok.paren {
+		if tok.text == ")" {
+			return nil, fmt.Errorf("unexpected \")\"")
+		}
+		p.pos++
+		expr, err := p.parseOr(negated)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || !p.tokens[p.pos].paren || p.tokens[p.pos].text != ")" {
+			return nil, fmt.Errorf("missing \")\"")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	if p.keyword("AND") || p.keyword("OR") {
+		return nil, fmt.Errorf("missing search term before %s", tok.text)
+	}
+	p.pos++
+
+	if tok.field == "" {
+		if !negated {
+			p.fuzzy = append(p.fuzzy, tok.text)
+		}
+		return fuzzyExpr{pattern: tok.text}, nil
+	}
+	return compileField(tok.field, tok.text)
+}
+
+// compileField 把 "字段:值" 编译为判断函数
+func compileField(field, value string) (queryExpr, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s: needs a value", field)
+	}
+
+	switch strings.ToLower(field) {
+	case "ext":
+		exts := make(map[string]bool)
+		for _, ext := range strings.Split(value, ",") {
+			if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+				exts[strings.ToLower(ext)] = true
+			}
+		}
+		return predicateExpr(func(n *model.Node, _ string) bool {
+			return !n.IsDir && exts[strings.ToLower(strings.TrimPrefix(path.Ext(n.Name), "."))]
+		}), nil
+
+	case "name":
+		re, err := regexp.Compile("(?i)^" + globToRegexp(value) + "$")
+		if err != nil {
+			return nil, fmt.Errorf("name: invalid pattern %q", value)
+		}
+		return predicateExpr(func(n *model.Node, _ string) bool { return re.MatchString(n.Name) }), nil
+
+	case "path":
+		re, err := regexp.Compile("^" + globToRegexp(strings.Trim(value, "/")) + "$")
+		if err != nil {
+			return nil, fmt.Errorf("path: invalid pattern %q", value)
+		}
+		// 匹配路径本身或它的任一上级文件夹，例如 path:cmd 包含 cmd 下的所有内容
+		return predicateExpr(func(_ *model.Node, relPath string) bool {
+			for p := relPath; p != "." && p != ""; p = path.Dir(p) {
+				if re.MatchString(p) {
+					return true
+				}
+			}
+			return false
+		}), nil
+
+	case "re":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("re: %v", err)
+		}
+		return predicateExpr(func(_ *model.Node, relPath string) bool { return re.MatchString(relPath) }), nil
+
+	case "git":
+		return compileGitField(value)
+
+	case "ann":
+		if value == "*" {
+			return predicateExpr(func(n *model.Node, _ string) bool { return n.Annotation != "" }), nil
+		}
+		needle := strings.ToLower(value)
+		return predicateExpr(func(n *model.Node, _ string) bool {
+			return strings.Contains(strings.ToLower(n.Annotation), needle)
+		}), nil
+
+	case "type":
+		switch strings.ToLower(value) {
+		case "f", "file":
+			return predicateExpr(func(n *model.Node, _ string) bool { return !n.IsDir }), nil
+		case "d", "dir":
+			return predicateExpr(func(n *model.Node, _ string) bool { return n.IsDir }), nil
+		}
+		return nil, fmt.Errorf("type: expected file or dir, got %q", value)
+	}
+	return nil, fmt.Errorf("unknown field %q (use ext, name, path, re, git, ann or type)", field+":")
+}
+
+// compileGitField 编译 git: 条件，可以用逗号列出多个状态
+func compileGitField(value string) (queryExpr, error) {
+	var checks []func(n *model.Node) bool
+	for _, v := range strings.Split(value, ",") {
+		switch v = strings.TrimSpace(v); strings.ToLower(v) {
+		case "changed", "any":
+			checks = append(checks, func(n *model.Node) bool { return n.GitStatus != "" && n.GitStatus != model.GitIgnored })
+		case "staged":
+			checks = append(checks, func(n *model.Node) bool { return n.IsStaged() || n.IsPartiallyStaged() })
+		case "ignored", "i":
+			checks = append(checks, func(n *model.Node) bool { return n.GitStatus == model.GitIgnored })
+		default:
+			code := strings.ToUpper(v)
+			switch code {
+			case "?":
+				code = model.GitAdded // 未追踪按新增显示
+			case "+":
+				code = model.GitAdded
+			case "-":
+				code = model.GitDeleted
+			}
+			switch code {
+			case model.GitModified, model.GitAdded, model.GitDeleted, model.GitRenamed,
+				model.GitCopied, model.GitTypeChanged, model.GitConflict, model.GitIgnored:
+				checks = append(checks, func(n *model.Node) bool { return n.GitStatus == code })
+			default:
+				return nil, fmt.Errorf("git: unknown status %q (use M A D R C T U ! changed staged ignored)", v)
+			}
+		}
+	}
+	return predicateExpr(func(n *model.Node, _ string) bool {
+		for _, check := range checks {
+			if check(n) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
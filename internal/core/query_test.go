@@ -0,0 +1,177 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// queryFixture 是查询测试使用的节点 (相对路径 -> 节点)
+type queryFixture struct {
+	rel  string
+	node *model.Node
+}
+
+func queryNodes() []queryFixture {
+	return []queryFixture{
+		{"cmd", &model.Node{Name: "cmd", IsDir: true}},
+		{"cmd/main.go", &model.Node{Name: "main.go", Annotation: "Entry point", GitStatus: model.GitModified, GitWorktree: "M"}},
+		{"cmd/main_test.go", &model.Node{Name: "main_test.go", GitStatus: model.GitAdded, GitIndex: "A"}},
+		{"docs", &model.Node{Name: "docs", IsDir: true}},
+		{"docs/guide.md", &model.Node{Name: "guide.md", Annotation: "TODO: rewrite"}},
+		{"lib", &model.Node{Name: "lib", IsDir: true}},
+		{"lib/util.go", &model.Node{Name: "util.go", GitStatus: model.GitRenamed, GitIndex: "R", GitWorktree: "M"}},
+		{"README.MD", &model.Node{Name: "README.MD"}},
+		{"build.log", &model.Node{Name: "build.log", GitStatus: model.GitIgnored, GitIndex: "!", GitWorktree: "!"}},
+	}
+}
+
+// matchQuery 返回满足查询的节点的相对路径
+func matchQuery(t *testing.T, input string) []string {
+	t.Helper()
+	q, err := ParseQuery(input)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", input, err)
+	}
+	matched := []string{}
+	for _, f := range queryNodes() {
+		if q.Match(f.node, f.rel) {
+			matched = append(matched, f.rel)
+		}
+	}
+	return matched
+}
+
+func TestQueryPrecedence(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// OR 的优先级低于 AND (包括省略的 AND)
+		{"ext:go OR ext:md git:M", []string{"cmd/main.go", "cmd/main_test.go", "lib/util.go"}},
+		{"ext:go OR ext:md AND git:M", []string{"cmd/main.go", "cmd/main_test.go", "lib/util.go"}},
+		{"ext:go AND git:R OR ann:todo", []string{"docs/guide.md", "lib/util.go"}},
+		{"(ext:go OR ext:md) git:M", []string{"cmd/main.go"}},
+		{"ext:go AND (git:R OR ann:todo)", []string{"lib/util.go"}},
+
+		// NOT 只作用于紧随其后的条件
+		{"NOT ext:go ext:md", []string{"docs/guide.md", "README.MD"}},
+		{"NOT ext:go OR type:dir", []string{"cmd", "docs", "docs/guide.md", "lib", "README.MD", "build.log"}},
+		{"NOT (ext:go OR type:dir)", []string{"docs/guide.md", "README.MD", "build.log"}},
+		{"NOT NOT ext:md", []string{"docs/guide.md", "README.MD"}},
+
+		// "-" 前缀等同于 NOT
+		{"-ext:go -type:dir", []string{"docs/guide.md", "README.MD", "build.log"}},
+		{"ext:go -test", []string{"cmd/main.go", "lib/util.go"}},
+
+		// 模糊匹配词与省略的 AND
+		{"ext:go main", []string{"cmd/main.go", "cmd/main_test.go"}},
+		{"ext:go AND main", []string{"cmd/main.go", "cmd/main_test.go"}},
+
+		// 带引号的关键字按普通词处理
+		{`ext:go "OR"`, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := matchQuery(t, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// ext: 忽略大小写与开头的点，只匹配文件
+		{"ext:go", []string{"cmd/main.go", "cmd/main_test.go", "lib/util.go"}},
+		{"ext:.GO,md", []string{"cmd/main.go", "cmd/main_test.go", "docs/guide.md", "lib/util.go", "README.MD"}},
+		{"ext:log,", []string{"build.log"}},
+
+		// ann: 忽略大小写的子串，ann:* 表示有注释
+		{"ann:*", []string{"cmd/main.go", "docs/guide.md"}},
+		{"ann:entry", []string{"cmd/main.go"}},
+		{`ann:"entry point"`, []string{"cmd/main.go"}},
+		{`ann:"point entry"`, []string{}},
+		{`ann:"todo:"`, []string{"docs/guide.md"}},
+
+		// git: 状态码、别名与逗号列表
+		{"git:M", []string{"cmd/main.go"}},
+		{"git:m", []string{"cmd/main.go"}},
+		{"git:?", []string{"cmd/main_test.go"}},
+		{"git:R,A", []string{"cmd/main_test.go", "lib/util.go"}},
+		{"git:changed", []string{"cmd/main.go", "cmd/main_test.go", "lib/util.go"}},
+		{"git:staged", []string{"cmd/main_test.go", "lib/util.go"}},
+		{"git:ignored", []string{"build.log"}},
+		{"git:!", []string{"build.log"}},
+		{"-git:changed ext:go", []string{}},
+
+		// 其他字段
+		{"type:dir", []string{"cmd", "docs", "lib"}},
+		{"type:f name:*.md", []string{"docs/guide.md", "README.MD"}},
+		{"name:*_test.go", []string{"cmd/main_test.go"}},
+		{"path:docs", []string{"docs", "docs/guide.md"}},
+		{"re:^lib/", []string{"lib/util.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := matchQuery(t, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // 错误信息包含的文字
+	}{
+		{"ext:", "needs a value"},
+		{"git:X", "unknown status"},
+		{"type:link", "expected file or dir"},
+		{"foo:bar", "unknown field"},
+		{"re:(", "re:"},
+		{`ann:"abc`, "unterminated quote"},
+		{"(ext:go", `missing ")"`},
+		{"ext:go)", `unexpected ")"`},
+		{"OR ext:go", "missing search term before OR"},
+		{"ext:go OR", "missing search term at end"},
+		{"NOT", "missing search term at end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseQuery(%q) error = %v, want it to contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryEmptyAndFuzzyTerms(t *testing.T) {
+	// 空查询返回 nil，nil 查询匹配所有节点
+	q, err := ParseQuery("   ")
+	if err != nil || q != nil {
+		t.Fatalf("ParseQuery(blank) = (%v, %v), want (nil, nil)", q, err)
+	}
+	if !q.Match(&model.Node{Name: "x"}, "x") {
+		t.Error("nil query should match every node")
+	}
+
+	// 只有不在 NOT 之下的模糊匹配词参与打分
+	q, err = ParseQuery("main -test NOT (util OR ext:md) ann:x guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"main", "guide"}; !reflect.DeepEqual(q.fuzzy, want) {
+		t.Errorf("fuzzy terms = %v, want %v", q.fuzzy, want)
+	}
+}
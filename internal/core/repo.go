@@ -0,0 +1,124 @@
+package core
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitRepo 描述扫描目录所在的 Git 仓库
+// git 输出的路径都相对于仓库根目录，而树中的路径相对于扫描目录，两者需要转换
+type GitRepo struct {
+	TopLevel string // 仓库根目录 (work tree) 的绝对路径
+	Prefix   string // 扫描目录相对仓库根目录的路径，"/" 分隔，扫描仓库根目录时为 ""
+}
+
+// FindGitRepo 检测扫描目录所在仓库的根目录 (git rev-parse --show-toplevel)
+// 不是 Git 仓库时返回错误
+func FindGitRepo(rootPath string) (*GitRepo, error) {
+	// --show-prefix 直接给出相对路径，避免符号链接导致 filepath.Rel 计算出错
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel", "--show-prefix")
+	cmd.Dir = rootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitError("git rev-parse", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(output), "\r\n"), "\n")
+	repo := &GitRepo{TopLevel: filepath.FromSlash(strings.TrimSpace(lines[0]))}
+	if len(lines) > 1 {
+		repo.Prefix = strings.TrimSuffix(strings.TrimSpace(lines[1]), "/")
+	}
+	return repo, nil
+}
+
+// RelPath 将相对仓库根目录的路径转换为相对扫描目录的路径
+// 路径不在扫描目录之内时返回 false
+func (r *GitRepo) RelPath(repoPath string) (string, bool) {
+	if r.Prefix == "" {
+		return repoPath, true
+	}
+	if repoPath == r.Prefix {
+		return ".", true
+	}
+	if strings.HasPrefix(repoPath, r.Prefix+"/") {
+		return repoPath[len(r.Prefix)+1:], true
+	}
+	return "", false
+}
+
+// origPath 转换重命名来源：来源位于扫描目录之外时使用 "../" 形式的相对路径
+func (r *GitRepo) origPath(repoPath string) string {
+	if repoPath == "" {
+		return ""
+	}
+	if rel, ok := r.RelPath(repoPath); ok {
+		return rel
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(r.Prefix), filepath.FromSlash(repoPath))
+	if err != nil {
+		return repoPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// translateStatus 把 git 输出的状态表转换为相对扫描目录的路径，丢弃扫描目录之外的条目
+// 以 "/" 结尾的文件夹条目如果包含了扫描目录本身 (例如扫描目录位于未追踪的文件夹中)，
+// 会转换为 "./"，由根节点继承
+func (r *GitRepo) translateStatus(statusMap map[string]GitEntry) map[string]GitEntry {
+	if r.Prefix == "" {
+		return statusMap
+	}
+
+	result := make(map[string]GitEntry, len(statusMap))
+	for path, entry := range statusMap {
+		entry.OrigPath = r.origPath(entry.OrigPath)
+
+		if dir, isDir := strings.CutSuffix(path, "/"); isDir {
+			if strings.HasPrefix(r.Prefix+"/", dir+"/") {
+				result["./"] = entry
+				continue
+			}
+			if rel, ok := r.RelPath(dir); ok {
+				result[rel+"/"] = entry
+			}
+			continue
+		}
+
+		if rel, ok := r.RelPath(path); ok {
+			result[rel] = entry
+		}
+	}
+	return result
+}
+
+// translateLines 把行数统计表转换为相对扫描目录的路径
+func (r *GitRepo) translateLines(stats map[string]LineStat) map[string]LineStat {
+	if r.Prefix == "" {
+		return stats
+	}
+
+	result := make(map[string]LineStat, len(stats))
+	for path, stat := range stats {
+		if rel, ok := r.RelPath(path); ok {
+			result[rel] = stat
+		}
+	}
+	return result
+}
+
+// ancestorDirs 返回从仓库根目录到扫描目录 (不含) 之间的所有文件夹
+// 每一项是 (绝对路径, 相对仓库根目录的路径)
+func (r *GitRepo) ancestorDirs() [][2]string {
+	if r.Prefix == "" {
+		return nil
+	}
+	dirs := [][2]string{{r.TopLevel, ""}}
+
+	parts := strings.Split(r.Prefix, "/")
+	for i := 1; i < len(parts); i++ {
+		rel := strings.Join(parts[:i], "/")
+		dirs = append(dirs, [2]string{filepath.Join(r.TopLevel, filepath.FromSlash(rel)), rel})
+	}
+	return dirs
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// initRepo 在临时目录中创建 Git 仓库并提交 files (相对路径 -> 内容)，返回仓库根目录
+// 没有安装 git 时跳过测试
+func initRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// 与用户的全局配置隔离，并阻止 git 向临时目录之外查找仓库
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	root := realPath(t, t.TempDir())
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(root))
+	runGit(t, root, "init", "-q")
+	for rel, content := range files {
+		writeRepoFile(t, root, rel, content)
+	}
+	runGit(t, root, "add", "-A")
+	runGit(t, root, "commit", "-q", "-m", "init")
+	return root
+}
+
+// runGit 在 dir 中执行 git 命令，失败时终止测试
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+// writeRepoFile 写入仓库中的文件，必要时创建父文件夹
+func writeRepoFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, content)
+}
+
+// realPath 解析符号链接 (macOS 的临时目录位于 /var -> /private/var 之下)
+func realPath(t *testing.T, path string) string {
+	t.Helper()
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return real
+}
+
+func TestFindGitRepoSubdirectory(t *testing.T) {
+	root := initRepo(t, map[string]string{
+		"top.go":         "package top\n",
+		"sub/pkg/a.go":   "package pkg\n",
+		"other/other.go": "package other\n",
+	})
+
+	repo, err := FindGitRepo(filepath.Join(root, "sub", "pkg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := realPath(t, repo.TopLevel); got != root {
+		t.Errorf("TopLevel = %q, want %q", got, root)
+	}
+	if repo.Prefix != "sub/pkg" {
+		t.Errorf("Prefix = %q, want %q", repo.Prefix, "sub/pkg")
+	}
+
+	wantDirs := [][2]string{
+		{repo.TopLevel, ""},
+		{filepath.Join(repo.TopLevel, "sub"), "sub"},
+	}
+	if got := repo.ancestorDirs(); !reflect.DeepEqual(got, wantDirs) {
+		t.Errorf("ancestorDirs() = %v, want %v", got, wantDirs)
+	}
+}
+
+func TestLoadGitStatusSubdirectory(t *testing.T) {
+	root := initRepo(t, map[string]string{
+		"top.go":         "package top\n",
+		"sub/pkg/a.go":   "package pkg\n",
+		"other/other.go": "package other\n",
+	})
+
+	// 扫描目录内外各有改动，另外把一个文件从扫描目录外移进来
+	writeRepoFile(t, root, "sub/pkg/a.go", "package pkg\n\nvar x = 1\n")
+	writeRepoFile(t, root, "sub/pkg/new.go", "package pkg\n")
+	writeRepoFile(t, root, "other/other.go", "package changed\n")
+	runGit(t, root, "mv", "top.go", "sub/pkg/top.go")
+
+	status := LoadGitStatus(filepath.Join(root, "sub", "pkg"), false)
+
+	want := map[string]GitEntry{
+		"a.go":   {Index: ' ', Worktree: 'M'},
+		"new.go": {Index: '?', Worktree: '?'},
+		"top.go": {Index: 'R', Worktree: ' ', OrigPath: "../../top.go"},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("LoadGitStatus(sub/pkg) = %v, want %v", status, want)
+	}
+}
+
+func TestLoadGitStatusOutsideRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := realPath(t, t.TempDir())
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+
+	if _, err := FindGitRepo(dir); err == nil {
+		t.Error("FindGitRepo outside a repository should fail")
+	}
+	if status := LoadGitStatus(dir, false); len(status) != 0 {
+		t.Errorf("LoadGitStatus outside a repository = %v, want empty", status)
+	}
+	if stats := LoadGitNumstat(dir, ""); len(stats) != 0 {
+		t.Errorf("LoadGitNumstat outside a repository = %v, want empty", stats)
+	}
+}
+
+func TestFindGitRepoNested(t *testing.T) {
+	outer := initRepo(t, map[string]string{"outer.txt": "outer"})
+
+	// 嵌套的独立仓库：它的状态与外层仓库无关
+	inner := filepath.Join(outer, "inner")
+	writeRepoFile(t, outer, "inner/inner.txt", "inner")
+	runGit(t, inner, "init", "-q")
+	runGit(t, inner, "add", "-A")
+	runGit(t, inner, "commit", "-q", "-m", "inner")
+	writeRepoFile(t, outer, "inner/inner.txt", "changed")
+
+	repo, err := FindGitRepo(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := realPath(t, repo.TopLevel); got != inner || repo.Prefix != "" {
+		t.Errorf("nested repo = (%q, %q), want (%q, \"\")", got, repo.Prefix, inner)
+	}
+	want := map[string]GitEntry{"inner.txt": {Index: ' ', Worktree: 'M'}}
+	if status := LoadGitStatus(inner, false); !reflect.DeepEqual(status, want) {
+		t.Errorf("LoadGitStatus(inner) = %v, want %v", status, want)
+	}
+}
+
+func TestFindGitRepoWorktree(t *testing.T) {
+	root := initRepo(t, map[string]string{"a/b.txt": "b"})
+
+	// worktree 的 .git 是一个文件，子目录的前缀仍然相对 worktree 的根目录
+	wt := realPath(t, t.TempDir())
+	runGit(t, root, "worktree", "add", "-q", wt)
+	writeRepoFile(t, wt, "a/c.txt", "c")
+
+	repo, err := FindGitRepo(filepath.Join(wt, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := realPath(t, repo.TopLevel); got != wt || repo.Prefix != "a" {
+		t.Errorf("worktree repo = (%q, %q), want (%q, \"a\")", got, repo.Prefix, wt)
+	}
+	want := map[string]GitEntry{"c.txt": {Index: '?', Worktree: '?'}}
+	if status := LoadGitStatus(filepath.Join(wt, "a"), false); !reflect.DeepEqual(status, want) {
+		t.Errorf("LoadGitStatus(worktree/a) = %v, want %v", status, want)
+	}
+}
+
+func TestTranslateStatus(t *testing.T) {
+	repo := &GitRepo{TopLevel: "/repo", Prefix: "src/app"}
+	status := map[string]GitEntry{
+		"src/app/main.go":   {Index: 'M', Worktree: ' '},
+		"src/app/gen/":      {Index: '?', Worktree: '?'},
+		"src/other.go":      {Index: 'M', Worktree: ' '},
+		"README.md":         {Index: ' ', Worktree: 'M'},
+		"src/app/moved.go":  {Index: 'R', Worktree: ' ', OrigPath: "lib/moved.go"},
+		"src/app/inside.go": {Index: 'R', Worktree: ' ', OrigPath: "src/app/old.go"},
+		"src/":              {Index: '!', Worktree: '!'}, // 包含扫描目录本身的文件夹
+	}
+
+	want := map[string]GitEntry{
+		"main.go":   {Index: 'M', Worktree: ' '},
+		"gen/":      {Index: '?', Worktree: '?'},
+		"moved.go":  {Index: 'R', Worktree: ' ', OrigPath: "../../lib/moved.go"},
+		"inside.go": {Index: 'R', Worktree: ' ', OrigPath: "old.go"},
+		"./":        {Index: '!', Worktree: '!'},
+	}
+	if got := repo.translateStatus(status); !reflect.DeepEqual(got, want) {
+		t.Errorf("translateStatus() = %v, want %v", got, want)
+	}
+
+	// 扫描仓库根目录时原样返回
+	rootRepo := &GitRepo{TopLevel: "/repo"}
+	if got := rootRepo.translateStatus(status); !reflect.DeepEqual(got, status) {
+		t.Errorf("translateStatus() at the repo root changed the map: %v", got)
+	}
+}
+
+func TestGitRepoRelPath(t *testing.T) {
+	repo := &GitRepo{Prefix: "src"}
+	tests := []struct {
+		in     string
+		want   string
+		inside bool
+	}{
+		{"src", ".", true},
+		{"src/a.go", "a.go", true},
+		{"src/x/y.go", "x/y.go", true},
+		{"srcx/a.go", "", false},
+		{"lib/a.go", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := repo.RelPath(tt.in)
+		if got != tt.want || ok != tt.inside {
+			t.Errorf("RelPath(%q) = (%q, %v), want (%q, %v)", tt.in, got, ok, tt.want, tt.inside)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// 子节点的排序方式
+const (
+	SortName    = "name"    // 按文件名 (与 os.ReadDir 的顺序一致，默认)
+	SortFolders = "folders" // 文件夹在前，其余按文件名
+	SortExt     = "ext"     // 按扩展名，相同时按文件名；文件夹在前
+	SortChanges = "changes" // 按 Git 行数变化从多到少，相同时按文件名
+)
+
+// SortModes 是所有的排序方式，按切换顺序排列
+var SortModes = []string{SortName, SortFolders, SortExt, SortChanges}
+
+// SortTree 按指定方式递归排序所有子节点 (稳定排序)
+// 新插入的节点 (文件操作、幽灵节点) 总是按文件名插入，改变树之后需要重新调用
+func SortTree(node *model.Node, mode string) {
+	less := sortLess(mode)
+	var walk func(n *model.Node)
+	walk = func(n *model.Node) {
+		sort.SliceStable(n.Children, func(i, j int) bool {
+			return less(n.Children[i], n.Children[j])
+		})
+		for _, child := range n.Children {
+			if child.IsDir {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+}
+
+// sortLess 返回排序方式对应的比较函数，未知的方式按文件名排序
+func sortLess(mode string) func(a, b *model.Node) bool {
+	byName := func(a, b *model.Node) bool { return a.Name < b.Name }
+
+	switch mode {
+	case SortFolders:
+		return func(a, b *model.Node) bool {
+			if a.IsDir != b.IsDir {
+				return a.IsDir
+			}
+			return byName(a, b)
+		}
+
+	case SortExt:
+		return func(a, b *model.Node) bool {
+			if a.IsDir != b.IsDir {
+				return a.IsDir
+			}
+			if !a.IsDir {
+				if extA, extB := sortExt(a.Name), sortExt(b.Name); extA != extB {
+					return extA < extB
+				}
+			}
+			return byName(a, b)
+		}
+
+	case SortChanges:
+		return func(a, b *model.Node) bool {
+			changesA, changesB := a.LinesAdded+a.LinesRemoved, b.LinesAdded+b.LinesRemoved
+			if changesA != changesB {
+				return changesA > changesB
+			}
+			return byName(a, b)
+		}
+	}
+	return byName
+}
+
+// sortExt 返回用于排序的扩展名 (小写)，".gitignore" 这类点开头的文件名视为没有扩展名
+func sortExt(name string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
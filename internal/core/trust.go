@@ -0,0 +1,75 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// 用户允许运行的项目命令保存在用户缓存目录中 (gentr/trusted-commands.json)
+// 每条记录是 项目根目录 + 命令内容 的哈希，命令被修改后需要重新确认；删除该文件只会让确认重新出现
+
+// IsCommandTrusted 判断用户是否已经允许在该项目中运行这条命令
+func IsCommandTrusted(rootPath, run string) bool {
+	trusted, _ := loadTrusted()
+	return trusted[commandHash(rootPath, run)]
+}
+
+// TrustCommand 记录用户允许在该项目中运行这条命令
+func TrustCommand(rootPath, run string) error {
+	path, err := trustPath()
+	if err != nil {
+		return err
+	}
+	trusted, _ := loadTrusted()
+	trusted[commandHash(rootPath, run)] = true
+
+	var hashes []string
+	for hash := range trusted {
+		hashes = append(hashes, hash)
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// loadTrusted 读取已允许的命令，文件不存在或损坏时返回空集合
+func loadTrusted() (map[string]bool, error) {
+	trusted := make(map[string]bool)
+	path, err := trustPath()
+	if err != nil {
+		return trusted, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return trusted, err
+	}
+	var hashes []string
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return trusted, err
+	}
+	for _, hash := range hashes {
+		trusted[hash] = true
+	}
+	return trusted, nil
+}
+
+func trustPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "gentr", "trusted-commands.json"), nil
+}
+
+func commandHash(rootPath, run string) string {
+	sum := sha256.Sum256([]byte(rootPath + "\x00" + run))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,390 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 用户级配置文件的文件名，位于 UserConfigDir 下 (两者都存在时使用 TOML)
+var UserConfigFileNames = []string{"config.toml", "config.json"}
+
+// 设置的来源，优先级从低到高 (命令行参数的来源是参数名，例如 "--force")
+const (
+	SourceDefault = "default"
+	SourceUser    = "user config"
+	SourceProject = ConfigFileName
+)
+
+// Settings 是可以在用户配置与项目的 .gentr.json 中设置的选项
+// 未设置的字段为 nil 或零值，由优先级更低的来源补齐
+type Settings struct {
+	MaxFiles    *int                `json:"max_files,omitempty"`    // 最大文件节点数
+	MaxDepth    *int                `json:"max_depth,omitempty"`    // 最大递归深度
+	NoGitignore *bool               `json:"no_gitignore,omitempty"` // 是否无视 .gitignore (ignore 中的规则仍然生效)
+	Lazy        *bool               `json:"lazy,omitempty"`         // 是否懒加载 (文件夹展开时再读取)
+	Ignore      []string            `json:"ignore,omitempty"`       // 额外的忽略规则 (gitignore 语法，相对扫描目录)
+	Theme       string              `json:"theme,omitempty"`        // SVG 主题: dark, light (未设置时 TUI 导出两套)
+	ExportDir   string              `json:"export_dir,omitempty"`   // TUI 导出文件的目录 (相对路径相对扫描目录)
+	UpdateCheck *bool               `json:"update_check,omitempty"` // 启动时是否检查新版本
+	Keys        map[string][]string `json:"keys,omitempty"`         // 按键绑定：动作名 -> 按键列表，例如 {"git_filter": ["G"]}
+	Commands    []Command           `json:"commands,omitempty"`     // 自定义命令，见 Command
+}
+
+// ResolvedSettings 是合并所有来源后的生效设置
+type ResolvedSettings struct {
+	MaxFiles    int
+	MaxDepth    int
+	NoGitignore bool
+	Lazy        bool
+	Ignore      []string
+	Theme       string
+	ExportDir   string
+	UpdateCheck bool
+	Keys        map[string][]string
+	Commands    []Command // 用户配置中的命令在前；项目中按键相同的命令覆盖用户配置中的
+
+	// 设置名 (与 JSON 字段名一致，按键为 "keys.<动作名>") -> 来源
+	Sources map[string]string
+}
+
+// UserConfigDir 返回用户级配置目录，依次查找：
+// $XDG_CONFIG_HOME/gentr、已存在的 ~/.config/gentr、系统默认的配置目录下的 gentr
+// (os.UserConfigDir 在 macOS 与 Windows 上不读取 XDG_CONFIG_HOME，因此先自行检查)
+func UserConfigDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "gentr"), nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dir := filepath.Join(home, ".config", "gentr")
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "gentr"), nil
+}
+
+// LoadUserConfig 读取用户级配置 (config.toml 或 config.json)，返回实际读取的文件路径
+// 文件不存在时返回空配置与空路径，格式错误或包含未知的设置时返回错误
+func LoadUserConfig() (Settings, string, error) {
+	var settings Settings
+	dir, err := UserConfigDir()
+	if err != nil {
+		return settings, "", nil // 没有配置目录 (例如未设置 HOME)，使用默认配置
+	}
+
+	for _, name := range UserConfigFileNames {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return settings, path, err
+		}
+
+		// TOML 先转换为与 JSON 相同的结构，两种格式共用字段名与检查
+		if filepath.Ext(name) == ".toml" {
+			var table map[string]any
+			if err := toml.Unmarshal(data, &table); err != nil {
+				return settings, path, fmt.Errorf("invalid TOML: %v", err)
+			}
+			if data, err = json.Marshal(table); err != nil {
+				return settings, path, err
+			}
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&settings); err != nil {
+			return settings, path, fmt.Errorf("invalid config: %v", err)
+		}
+		return settings, path, settings.validate()
+	}
+	return settings, "", nil
+}
+
+// LoadProjectSettings 读取项目 .gentr.json 中的设置
+// 文件不存在时返回空设置，无法读取或解析时返回错误
+func LoadProjectSettings(rootPath string) (Settings, error) {
+	var config ConfigFile
+	data, err := os.ReadFile(filepath.Join(rootPath, ConfigFileName))
+	if os.IsNotExist(err) {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Settings{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if err := config.Settings.validate(); err != nil {
+		return Settings{}, err
+	}
+	// 项目配置来自仓库，不能把导出文件写到项目之外
+	if dir := config.Settings.ExportDir; dir != "" && !isProjectRelative(dir) {
+		return Settings{}, fmt.Errorf("export_dir %q must be a relative path inside the project", dir)
+	}
+	return config.Settings, nil
+}
+
+// isProjectRelative 判断路径是否是位于项目之内的相对路径 (不以 "~" 开头，不含 "..")
+func isProjectRelative(dir string) bool {
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, "~") || filepath.VolumeName(dir) != "" {
+		return false
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(dir))
+	return cleaned != ".." && !strings.HasPrefix(cleaned, ".."+string(filepath.Separator))
+}
+
+// validate 检查设置的取值
+func (s Settings) validate() error {
+	if s.MaxFiles != nil && *s.MaxFiles < 1 {
+		return fmt.Errorf("max_files must be at least 1")
+	}
+	if s.MaxDepth != nil && *s.MaxDepth < 1 {
+		return fmt.Errorf("max_depth must be at least 1")
+	}
+	if s.Theme != "" && !strings.EqualFold(s.Theme, "dark") && !strings.EqualFold(s.Theme, "light") {
+		return fmt.Errorf("unknown theme %q (expected dark or light)", s.Theme)
+	}
+	return nil
+}
+
+// ResolveSettings 按 用户配置 < 项目配置 的优先级合并设置，未设置的使用内置默认值
+// 忽略规则会叠加 (项目规则在后，可以用 "!" 重新包含)，按键绑定按动作覆盖
+// 命令行参数由调用方在之后通过 Set* 方法覆盖
+func ResolveSettings(user, project Settings) ResolvedSettings {
+	r := ResolvedSettings{
+		MaxFiles:    DefaultMaxFiles,
+		MaxDepth:    DefaultMaxDepth,
+		UpdateCheck: true,
+		Keys:        make(map[string][]string),
+		Sources: map[string]string{
+			"max_files":    SourceDefault,
+			"max_depth":    SourceDefault,
+			"no_gitignore": SourceDefault,
+			"lazy":         SourceDefault,
+			"ignore":       SourceDefault,
+			"theme":        SourceDefault,
+			"export_dir":   SourceDefault,
+			"update_check": SourceDefault,
+			"commands":     SourceDefault,
+		},
+	}
+
+	var ignoreSources, commandSources []string
+	for _, layer := range []struct {
+		settings Settings
+		source   string
+	}{{user, SourceUser}, {project, SourceProject}} {
+		s := layer.settings
+		if s.MaxFiles != nil {
+			r.MaxFiles = *s.MaxFiles
+			r.Sources["max_files"] = layer.source
+		}
+		if s.MaxDepth != nil {
+			r.MaxDepth = *s.MaxDepth
+			r.Sources["max_depth"] = layer.source
+		}
+		if s.NoGitignore != nil {
+			r.NoGitignore = *s.NoGitignore
+			r.Sources["no_gitignore"] = layer.source
+		}
+		if s.Lazy != nil {
+			r.Lazy = *s.Lazy
+			r.Sources["lazy"] = layer.source
+		}
+		if len(s.Ignore) > 0 {
+			r.Ignore = append(r.Ignore, s.Ignore...)
+			ignoreSources = append(ignoreSources, layer.source)
+		}
+		if s.Theme != "" {
+			r.Theme = strings.ToLower(s.Theme)
+			r.Sources["theme"] = layer.source
+		}
+		if s.ExportDir != "" {
+			r.ExportDir = s.ExportDir
+			r.Sources["export_dir"] = layer.source
+		}
+		if s.UpdateCheck != nil {
+			r.UpdateCheck = *s.UpdateCheck
+			r.Sources["update_check"] = layer.source
+		}
+		for action, keys := range s.Keys {
+			r.Keys[action] = keys
+			r.Sources["keys."+action] = layer.source
+		}
+		if len(s.Commands) > 0 {
+			r.Commands = mergeCommands(r.Commands, s.Commands, layer.source)
+			commandSources = append(commandSources, layer.source)
+		}
+	}
+	if len(ignoreSources) > 0 {
+		r.Sources["ignore"] = strings.Join(ignoreSources, " + ")
+	}
+	if len(commandSources) > 0 {
+		r.Sources["commands"] = strings.Join(commandSources, " + ")
+	}
+	return r
+}
+
+// mergeCommands 追加一层自定义命令并记录来源，按键相同的命令覆盖之前的命令
+func mergeCommands(base, layer []Command, source string) []Command {
+	keys := make(map[string]bool)
+	for _, c := range layer {
+		if c.Key != "" {
+			keys[c.Key] = true
+		}
+	}
+
+	var merged []Command
+	for _, c := range base {
+		if !keys[c.Key] {
+			merged = append(merged, c)
+		}
+	}
+	for _, c := range layer {
+		c.Source = source
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// SetMaxFiles 用命令行参数覆盖最大文件节点数，source 是参数名
+func (r *ResolvedSettings) SetMaxFiles(n int, source string) {
+	r.MaxFiles = n
+	r.Sources["max_files"] = source
+}
+
+// SetMaxDepth 用命令行参数覆盖最大递归深度，source 是参数名
+func (r *ResolvedSettings) SetMaxDepth(n int, source string) {
+	r.MaxDepth = n
+	r.Sources["max_depth"] = source
+}
+
+// SetNoGitignore 用命令行参数关闭 .gitignore，source 是参数名
+func (r *ResolvedSettings) SetNoGitignore(source string) {
+	r.NoGitignore = true
+	r.Sources["no_gitignore"] = source
+}
+
+// SetLazy 用命令行参数开启懒加载，source 是参数名
+func (r *ResolvedSettings) SetLazy(source string) {
+	r.Lazy = true
+	r.Sources["lazy"] = source
+}
+
+// ApplyForce 应用 Force 模式：取消所有限制，并且不加载任何忽略规则 (包括 ignore 中的规则)
+func (r *ResolvedSettings) ApplyForce(source string) {
+	force := ForceOptions()
+	r.SetMaxFiles(force.MaxFiles, source)
+	r.SetMaxDepth(force.MaxDepth, source)
+	r.SetNoGitignore(source)
+	if len(r.Ignore) > 0 {
+		r.Ignore = nil
+		r.Sources["ignore"] += " (not applied with " + source + ")"
+	}
+}
+
+// SetTheme 用命令行参数覆盖 SVG 主题，source 是参数名
+func (r *ResolvedSettings) SetTheme(theme, source string) {
+	r.Theme = strings.ToLower(theme)
+	r.Sources["theme"] = source
+}
+
+// KeySources 返回设置了按键绑定的来源 (去重并排序)，用于错误提示
+func (r ResolvedSettings) KeySources() []string {
+	seen := make(map[string]bool)
+	var sources []string
+	for action := range r.Keys {
+		if source := r.Sources["keys."+action]; !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// WalkOptions 返回生效设置对应的扫描选项
+func (r ResolvedSettings) WalkOptions() WalkOptions {
+	opts := DefaultOptions()
+	opts.MaxFiles = r.MaxFiles
+	opts.MaxDepth = r.MaxDepth
+	opts.IgnoreGitIgnore = r.NoGitignore
+	opts.Ignore = r.Ignore
+	opts.Lazy = r.Lazy
+	return opts
+}
+
+// ExportPath 返回 TUI 导出文件的路径：设置了 export_dir 时放在该目录下 (必要时创建)
+// "~" 开头的目录相对用户主目录，其余相对路径相对扫描目录；来自项目配置的目录必须位于扫描目录之内
+func (r ResolvedSettings) ExportPath(rootPath, filename string) (string, error) {
+	if r.ExportDir == "" {
+		return filename, nil
+	}
+	project := r.Sources["export_dir"] == SourceProject
+	if project && !isProjectRelative(r.ExportDir) {
+		return "", fmt.Errorf("export_dir %q from %s must stay inside the project", r.ExportDir, SourceProject)
+	}
+
+	dir := r.ExportDir
+	if rest, ok := strings.CutPrefix(dir, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, rest)
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(rootPath, dir)
+	}
+
+	// 符号链接也不能把项目配置的目录引到项目之外
+	if project {
+		if err := checkInsideRoot(rootPath, dir); err != nil {
+			return "", fmt.Errorf("export_dir %q from %s: %v", r.ExportDir, SourceProject, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// checkInsideRoot 检查 dir 解析符号链接后仍位于 rootPath 之内，dir 尚不存在时检查它已存在的上级
+func checkInsideRoot(rootPath, dir string) error {
+	root, err := filepath.EvalSymlinks(rootPath)
+	if err != nil {
+		return err
+	}
+	existing, rest := dir, ""
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			existing = filepath.Join(real, rest)
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	if rel, err := filepath.Rel(root, existing); err != nil || !isProjectRelative(rel) {
+		return fmt.Errorf("resolves to %s, outside the project", existing)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeUserConfig 在临时的用户配置目录中写入配置文件
+func writeUserConfig(t *testing.T, name, content string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home) // 同时隔离 ~/.config
+	dir, err := UserConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, name), content)
+}
+
+func TestUserConfigDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // Windows 的 os.UserHomeDir
+
+	// XDG_CONFIG_HOME 在所有系统上优先
+	xdg := filepath.Join(home, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if dir, err := UserConfigDir(); err != nil || dir != filepath.Join(xdg, "gentr") {
+		t.Errorf("with XDG_CONFIG_HOME: (%q, %v), want %q", dir, err, filepath.Join(xdg, "gentr"))
+	}
+
+	// 相对路径的 XDG_CONFIG_HOME 无效，回落到系统默认的配置目录
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Skip("no default config directory:", err)
+	}
+	if dir, err := UserConfigDir(); err != nil || dir != filepath.Join(configDir, "gentr") {
+		t.Errorf("without ~/.config/gentr: (%q, %v), want %q", dir, err, filepath.Join(configDir, "gentr"))
+	}
+
+	// 已存在的 ~/.config/gentr 优先于系统默认的配置目录
+	dotConfig := filepath.Join(home, ".config", "gentr")
+	if err := os.MkdirAll(dotConfig, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if dir, err := UserConfigDir(); err != nil || dir != dotConfig {
+		t.Errorf("with ~/.config/gentr: (%q, %v), want %q", dir, err, dotConfig)
+	}
+}
+
+func TestLoadUserConfigTOML(t *testing.T) {
+	writeUserConfig(t, "config.toml", `
+# 注释与行尾注释
+max_files = 20000 # 行尾注释
+lazy = true
+ignore = ["*.log", 'dist/']
+theme = "light"
+export_dir = "~/trees"
+
+[keys]
+git_filter = ["G"]
+delete = []
+
+[[commands]]
+key = "T"
+name = "Test \"pkg\""
+run = "go test {dir}"
+wait = true
+
+[[commands]]
+run = "make"
+`)
+
+	settings, path, err := LoadUserConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "config.toml" {
+		t.Errorf("path = %q, want config.toml", path)
+	}
+	if settings.MaxFiles == nil || *settings.MaxFiles != 20000 {
+		t.Errorf("MaxFiles = %v, want 20000", settings.MaxFiles)
+	}
+	if settings.Lazy == nil || !*settings.Lazy {
+		t.Errorf("Lazy = %v, want true", settings.Lazy)
+	}
+	if want := []string{"*.log", "dist/"}; !reflect.DeepEqual(settings.Ignore, want) {
+		t.Errorf("Ignore = %v, want %v", settings.Ignore, want)
+	}
+	if settings.Theme != "light" || settings.ExportDir != "~/trees" {
+		t.Errorf("Theme, ExportDir = %q, %q", settings.Theme, settings.ExportDir)
+	}
+	if want := map[string][]string{"git_filter": {"G"}, "delete": {}}; !reflect.DeepEqual(settings.Keys, want) {
+		t.Errorf("Keys = %v, want %v", settings.Keys, want)
+	}
+	wantCommands := []Command{
+		{Key: "T", Name: `Test "pkg"`, Run: "go test {dir}", Wait: true},
+		{Run: "make"},
+	}
+	if !reflect.DeepEqual(settings.Commands, wantCommands) {
+		t.Errorf("Commands = %+v, want %+v", settings.Commands, wantCommands)
+	}
+}
+
+func TestLoadUserConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string // 错误信息包含的文字
+	}{
+		{"invalid toml", "config.toml", "max_files = ", "invalid TOML"},
+		{"unknown toml key", "config.toml", "max_file = 10", "unknown field"},
+		{"unknown toml table key", "config.toml", "[[commands]]\nrun = \"x\"\nkeys = \"y\"", "unknown field"},
+		{"wrong toml type", "config.toml", `max_files = "many"`, "invalid config"},
+		{"invalid value", "config.toml", "max_depth = 0", "max_depth"},
+		{"invalid json", "config.json", "{", "invalid config"},
+		{"unknown json key", "config.json", `{"thme": "dark"}`, "unknown field"},
+		{"invalid theme", "config.json", `{"theme": "blue"}`, "unknown theme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeUserConfig(t, tt.file, tt.content)
+			_, _, err := LoadUserConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadUserConfig() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProjectSettings(t *testing.T) {
+	root := t.TempDir()
+
+	// 没有 .gentr.json 时返回空设置
+	settings, err := LoadProjectSettings(root)
+	if err != nil || !reflect.DeepEqual(settings, Settings{}) {
+		t.Errorf("missing file: (%+v, %v), want empty settings", settings, err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string // 错误信息包含的文字，为空表示成功
+	}{
+		{"valid", `{"nodes": {}, "max_files": 10, "export_dir": "docs/trees"}`, ""},
+		{"invalid json", `{"nodes": {`, "invalid JSON"},
+		{"invalid value", `{"max_files": 0}`, "max_files"},
+		{"absolute export_dir", `{"export_dir": "/tmp/out"}`, "inside the project"},
+		{"home export_dir", `{"export_dir": "~/trees"}`, "inside the project"},
+		{"parent export_dir", `{"export_dir": "docs/../../out"}`, "inside the project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFile(t, filepath.Join(root, ConfigFileName), tt.content)
+			_, err := LoadProjectSettings(root)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("LoadProjectSettings() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadProjectSettings() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportPathProjectSymlink(t *testing.T) {
+	root := realPath(t, t.TempDir())
+	outside := realPath(t, t.TempDir())
+	if err := os.Symlink(outside, filepath.Join(root, "out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	project := ResolveSettings(Settings{}, Settings{ExportDir: "out/trees"})
+	if _, err := project.ExportPath(root, "tree.txt"); err == nil {
+		t.Error("project export_dir through a symlink to outside the project should fail")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "trees")); !os.IsNotExist(err) {
+		t.Error("ExportPath created a folder outside the project")
+	}
+
+	project = ResolveSettings(Settings{}, Settings{ExportDir: "docs/trees"})
+	path, err := project.ExportPath(root, "tree.txt")
+	if err != nil || path != filepath.Join(root, "docs", "trees", "tree.txt") {
+		t.Errorf("ExportPath() = (%q, %v)", path, err)
+	}
+
+	// 用户配置中的目录不受限制
+	user := ResolveSettings(Settings{ExportDir: filepath.Join(outside, "mine")}, Settings{})
+	if _, err := user.ExportPath(root, "tree.txt"); err != nil {
+		t.Errorf("user export_dir outside the project: %v", err)
+	}
+}
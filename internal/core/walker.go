@@ -1,12 +1,18 @@
 package core
 
 import (
+	"context"
+	"fmt"
 	"math" // 用于 Force 模式的无限大常量
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/DoraleCitrus/gentr/internal/model"
-	ignore "github.com/sabhiram/go-gitignore"
 )
 
 // 默认安全限制常量
@@ -19,86 +25,382 @@ const (
 type WalkOptions struct {
 	MaxFiles        int
 	MaxDepth        int
-	IgnoreGitIgnore bool // 是否无视 .gitignore
+	IgnoreGitIgnore bool     // 是否无视 .gitignore (同时无视 Ignore 中的规则)
+	Ignore          []string // 额外的忽略规则 (gitignore 语法，相对扫描目录)，来自用户配置与 .gentr.json
+	DiffRef         string   // 非空时 Git 状态改为对比该 ref (git diff --name-status)
+	Lazy            bool     // 懒加载：只读取根目录，子文件夹在展开时由 LoadDir 读取 (MaxDepth 不生效，MaxFiles 按文件夹计算)
+
+	Workers    int            // 并发读取目录的协程数，<= 0 时使用 CPU 核数
+	OnProgress func(Progress) // 进度回调 (可选)，在调用 Walk 的协程中同步执行
 }
 
-// 计数器
-type counter struct {
-	count        int
-	limitReached bool
+// Progress 描述扫描进度
+type Progress struct {
+	Dirs  int // 已读取的文件夹数
+	Files int // 已加入树中的节点数 (包括文件夹)
 }
 
-// Walk 负责从根目录开始构建树
-func Walk(rootPath string, opts WalkOptions) (*model.Node, bool, error) {
-	// 根据配置决定是否加载.gitignore
-	var ignoreObj *ignore.GitIgnore
-	if !opts.IgnoreGitIgnore {
-		ignoreObj, _ = ignore.CompileIgnoreFile(filepath.Join(rootPath, ".gitignore"))
-	}
+// dirJob 是一个待读取的文件夹
+type dirJob struct {
+	node    *model.Node
+	relPath string
+	ignore  *IgnoreMatcher
+}
 
-	// 预加载 Git 状态
-	gitStatusMap := LoadGitStatus(rootPath)
+// dirResult 是读取一个文件夹得到的子节点 (已按文件名排序)
+type dirResult struct {
+	done     bool
+	children []*model.Node
+	jobs     []dirJob // 与 children 中的文件夹一一对应
+	err      error
+}
 
-	// 初始化计数器
-	c := &counter{count: 0}
+// LimitInfo 描述扫描在哪里被安全限制截断，零值表示没有触发限制
+type LimitInfo struct {
+	MaxFiles int // 扫描时使用的限制
+	MaxDepth int
 
-	// 开始扫描
-	// 传入 rootPath 和 gitStatusMap
-	// 传入 opts
-	root, err := scanDir(rootPath, rootPath, ignoreObj, 0, c, gitStatusMap, opts)
+	FilesDir  string // 触发文件数量限制的文件夹 (相对扫描目录，"." 为根目录)，未触发时为空
+	DepthDir  string // 因深度限制没有读取的第一个文件夹，未触发时为空
+	DepthDirs int    // 因深度限制没有读取的文件夹数
+}
 
-	// 返回结果，同时返回是否触发了限制
-	return root, c.limitReached, err
+// Reached 判断是否触发了任意一种限制
+func (l LimitInfo) Reached() bool {
+	return l.FilesDir != "" || l.DepthDir != ""
 }
 
-// scanDir 递归扫描目录，构建节点树
-func scanDir(path string, rootPath string, ignoreObj *ignore.GitIgnore, depth int, c *counter, gitMap map[string]string, opts WalkOptions) (*model.Node, error) {
-	// 深度熔断检查并使用 opts 中的配置
-	if depth > opts.MaxDepth {
-		return nil, nil // 超过深度，不再深入，直接返回 nil，会被上层过滤掉
+// Merge 合并另一次读取 (例如懒加载的文件夹) 触发的限制，已记录的位置优先
+func (l LimitInfo) Merge(other LimitInfo) LimitInfo {
+	if l.MaxFiles == 0 && l.MaxDepth == 0 {
+		l.MaxFiles, l.MaxDepth = other.MaxFiles, other.MaxDepth
+	}
+	if l.FilesDir == "" {
+		l.FilesDir = other.FilesDir
 	}
+	if l.DepthDir == "" {
+		l.DepthDir = other.DepthDir
+	}
+	l.DepthDirs += other.DepthDirs
+	return l
+}
 
-	// 数量熔断检查并使用 opts 中的配置
-	if c.count >= opts.MaxFiles {
-		c.limitReached = true // 标记触发限制
-		return nil, nil
+// String 描述触发的限制及其位置，例如 "5000-file limit hit in src/"
+func (l LimitInfo) String() string {
+	dirName := func(relPath string) string {
+		if relPath == "." {
+			return "./"
+		}
+		return relPath + "/"
 	}
 
-	// 获取文件或文件夹的基础信息
-	info, err := os.Stat(path)
+	var parts []string
+	if l.FilesDir != "" {
+		parts = append(parts, fmt.Sprintf("%d-file limit hit in %s", l.MaxFiles, dirName(l.FilesDir)))
+	}
+	if l.DepthDir != "" {
+		part := fmt.Sprintf("depth limit %d hit at %s", l.MaxDepth, dirName(l.DepthDir))
+		if l.DepthDirs > 1 {
+			part += fmt.Sprintf(" (+%d more folders)", l.DepthDirs-1)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Walk 负责从根目录开始构建树
+//
+// 扫描按层进行：同一层的文件夹由有界协程池并发读取，再按原有顺序依次汇总，
+// 因此无论调度顺序如何，输出 (包括触发数量限制时的截断位置) 都是确定的。
+// 触发限制时返回的 LimitInfo 记录截断的位置，ctx 被取消时返回 ctx.Err()。
+func Walk(ctx context.Context, rootPath string, opts WalkOptions) (*model.Node, LimitInfo, error) {
+	limits := LimitInfo{MaxFiles: opts.MaxFiles, MaxDepth: opts.MaxDepth}
+
+	info, err := os.Stat(rootPath)
 	if err != nil {
-		return nil, err
+		return nil, limits, err
 	}
 
-	// 计数
-	c.count++
+	// 根据配置决定是否加载忽略规则 (全局 excludesFile、info/exclude、根 .gitignore)
+	// 子目录中的 .gitignore 在扫描到对应目录时再叠加
+	ignoreObj := walkIgnore(rootPath, opts)
 
-	// 创建当前节点
-	node := &model.Node{
+	root := &model.Node{
 		Name:  info.Name(),
-		Path:  path,
+		Path:  rootPath,
 		IsDir: info.IsDir(),
 	}
+	if !root.IsDir || opts.MaxDepth < 1 {
+		return root, limits, nil
+	}
 
-	// 注入 Git 状态
-	// 计算相对路径以便在 gitMap 中查找
-	relPath, err := filepath.Rel(rootPath, path)
-	if err == nil {
-		relPath = filepath.ToSlash(relPath) // 统一为 "/" 分隔符，兼容不同系统
-		if status, ok := gitMap[relPath]; ok {
-			node.GitStatus = status
+	w := &walker{
+		opts:     opts,
+		progress: Progress{Files: 1}, // 根节点也计入总数
+	}
+
+	// 根目录必须能读取，否则直接报错
+	rootResult := w.scanDir(dirJob{node: root, relPath: ".", ignore: ignoreObj})
+	if rootResult.err != nil {
+		return nil, limits, rootResult.err
+	}
+
+	level := []dirJob{{node: root, relPath: ".", ignore: ignoreObj}}
+	results := []*dirResult{rootResult}
+
+	for depth := 0; len(level) > 0; depth++ {
+		if err := ctx.Err(); err != nil {
+			return nil, limits, err
+		}
+
+		// 汇总本层结果，得到下一层需要读取的文件夹
+		next := w.collect(level, results)
+		if w.limitDir != "" {
+			limits.FilesDir = w.limitDir
+			break
+		}
+		if opts.Lazy {
+			// 懒加载：子文件夹保持折叠，展开时再读取
+			for _, job := range next {
+				job.node.Unloaded = true
+				job.node.Collapsed = true
+			}
+			break
+		}
+		if depth+1 >= opts.MaxDepth {
+			// 还有文件夹没有读取，说明深度限制截断了树
+			if len(next) > 0 {
+				limits.DepthDir = next[0].relPath
+				limits.DepthDirs = len(next)
+			}
+			break
 		}
+
+		level = next
+		results = w.scanLevel(ctx, level)
 	}
 
-	// 如果不是文件夹，返回节点
-	if !info.IsDir() {
-		return node, nil
+	if err := ctx.Err(); err != nil {
+		return nil, limits, err
 	}
 
-	// 是文件夹则需要扫描子内容
-	entries, err := os.ReadDir(path)
+	// 注入 Git 状态与行数变化，并为已删除的文件补上幽灵节点
+	// Force 模式下被忽略的文件也会出现在树中，因此一并查询忽略状态
+	gitChanges, err := LoadGitChanges(rootPath, opts)
 	if err != nil {
-		return nil, err
+		return nil, limits, err
+	}
+	ApplyGitChanges(root, gitChanges)
+
+	// 返回结果，同时返回触发限制的位置
+	return root, limits, nil
+}
+
+// DirLoader 读取懒加载模式下的文件夹 (不修改树，可以在多个后台协程中同时使用)
+// 读取一个文件夹时记住其子文件夹使用的忽略规则，展开它们时不必重新叠加祖先的 .gitignore，也不再运行 git
+type DirLoader struct {
+	rootPath string
+	opts     WalkOptions
+
+	rootOnce sync.Once
+	root     *IgnoreMatcher // 根目录的匹配器，第一次读取时创建
+
+	mu      sync.Mutex
+	ignores map[string]*IgnoreMatcher // 文件夹的相对路径 -> 读取它时使用的匹配器 (祖先文件夹的规则)
+}
+
+// NewDirLoader 创建懒加载的文件夹读取器，重新扫描整个目录时应当重新创建
+func NewDirLoader(rootPath string, opts WalkOptions) *DirLoader {
+	return &DirLoader{
+		rootPath: rootPath,
+		opts:     opts,
+		ignores:  make(map[string]*IgnoreMatcher),
+	}
+}
+
+// Load 读取一个文件夹，返回其直接子节点
+// 子文件夹同样标记为未读取并折叠；子节点数超过 MaxFiles 时只保留前 MaxFiles 个
+func (l *DirLoader) Load(dirPath string) ([]*model.Node, LimitInfo, error) {
+	limits := LimitInfo{MaxFiles: l.opts.MaxFiles, MaxDepth: l.opts.MaxDepth}
+	relDir, err := filepath.Rel(l.rootPath, dirPath)
+	if err != nil {
+		return nil, limits, err
+	}
+	relDir = filepath.ToSlash(relDir)
+
+	// relDir 自身的规则由 scanDir 叠加
+	w := &walker{opts: l.opts}
+	res := w.scanDir(dirJob{node: &model.Node{Path: dirPath, IsDir: true}, relPath: relDir, ignore: l.ignoreFor(relDir)})
+	if res.err != nil {
+		return nil, limits, res.err
+	}
+
+	// 记住子文件夹的匹配器，展开它们时直接使用
+	l.mu.Lock()
+	for _, job := range res.jobs {
+		l.ignores[job.relPath] = job.ignore
+	}
+	l.mu.Unlock()
+
+	children := res.children
+	if len(children) > l.opts.MaxFiles {
+		children = children[:l.opts.MaxFiles]
+		limits.FilesDir = relDir
+	}
+	for _, child := range children {
+		if child.IsDir {
+			child.Unloaded = true
+			child.Collapsed = true
+		}
+	}
+	return children, limits, nil
+}
+
+// ignoreFor 返回读取 relDir 时使用的匹配器，没有记录时从最近的已知祖先开始叠加 .gitignore
+func (l *DirLoader) ignoreFor(relDir string) *IgnoreMatcher {
+	l.rootOnce.Do(func() { l.root = walkIgnore(l.rootPath, l.opts) })
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lookup func(rel string) *IgnoreMatcher
+	lookup = func(rel string) *IgnoreMatcher {
+		if m, ok := l.ignores[rel]; ok {
+			return m
+		}
+		m := l.root
+		if parent := path.Dir(rel); parent != "." {
+			m = lookup(parent).Child(filepath.Join(l.rootPath, filepath.FromSlash(parent)), parent)
+		}
+		l.ignores[rel] = m
+		return m
+	}
+	return lookup(relDir)
+}
+
+// walkIgnore 根据扫描选项创建根目录的忽略规则匹配器，无视 .gitignore 时仍然应用配置中的规则
+func walkIgnore(rootPath string, opts WalkOptions) *IgnoreMatcher {
+	switch {
+	case !opts.IgnoreGitIgnore:
+		return NewIgnoreMatcher(rootPath, opts.Ignore)
+	case len(opts.Ignore) > 0:
+		return NewPatternMatcher(rootPath, opts.Ignore)
+	}
+	return nil
+}
+
+// walker 保存一次扫描的共享状态
+type walker struct {
+	opts WalkOptions
+
+	progress Progress
+	limitDir string // 触发数量限制的文件夹 (相对路径)，未触发时为空
+
+	// seen 统计本层已读取到的子节点数，用于提前停止派发任务
+	seen atomic.Int64
+}
+
+// scanLevel 使用有界协程池并发读取同一层的所有文件夹
+func (w *walker) scanLevel(ctx context.Context, level []dirJob) []*dirResult {
+	results := make([]*dirResult, len(level))
+	for i := range results {
+		results[i] = &dirResult{}
+	}
+
+	workers := w.opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	w.seen.Store(0)
+	budget := int64(w.opts.MaxFiles - w.progress.Files)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				// 已被取消，或已读取的节点足以触发数量限制，剩余任务留给 collect 按需补读
+				if ctx.Err() != nil || w.seen.Load() >= budget {
+					continue
+				}
+				res := w.scanDir(level[idx])
+				w.seen.Add(int64(len(res.children)))
+				results[idx] = res
+			}
+		}()
+	}
+
+	for i := range level {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// collect 按顺序把本层读取结果挂到父节点上，并执行数量熔断
+func (w *walker) collect(level []dirJob, results []*dirResult) []dirJob {
+	var next []dirJob
+
+	for i, job := range level {
+		res := results[i]
+		if !res.done {
+			// 并发阶段跳过的文件夹，在确实需要时同步补读，保证结果与调度无关
+			res = w.scanDir(job)
+		}
+		// 读取失败时保留空文件夹节点，选择跳过而不是崩溃
+		if res.err != nil {
+			continue
+		}
+
+		w.progress.Dirs++
+
+		dirIdx := 0
+		for _, child := range res.children {
+			// 数量熔断检查并使用 opts 中的配置
+			if w.progress.Files >= w.opts.MaxFiles {
+				w.limitDir = job.relPath // 记录触发限制的位置
+				break
+			}
+
+			job.node.Children = append(job.node.Children, child)
+			w.progress.Files++
+
+			if child.IsDir {
+				next = append(next, res.jobs[dirIdx])
+				dirIdx++
+			}
+		}
+
+		if w.opts.OnProgress != nil {
+			w.opts.OnProgress(w.progress)
+		}
+
+		// 数量限制优化，提前跳出
+		if w.limitDir != "" {
+			return nil
+		}
+	}
+	return next
+}
+
+// scanDir 读取一个文件夹，构建其直接子节点
+func (w *walker) scanDir(job dirJob) *dirResult {
+	res := &dirResult{done: true}
+
+	entries, err := os.ReadDir(job.node.Path)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	// 进入子目录时叠加该目录下的 .gitignore (根目录的规则已经在 Walk 中加载)
+	ignoreObj := job.ignore
+	if job.relPath != "." {
+		ignoreObj = ignoreObj.Child(job.node.Path, job.relPath)
 	}
 
 	for _, entry := range entries {
@@ -107,33 +409,46 @@ func scanDir(path string, rootPath string, ignoreObj *ignore.GitIgnore, depth in
 			continue
 		}
 
+		// 构建子文件的完整路径与相对路径
+		fullPath := filepath.Join(job.node.Path, entry.Name())
+		childRel := entry.Name()
+		if job.relPath != "." {
+			childRel = job.relPath + "/" + entry.Name()
+		}
+
+		// 使用相对根目录的路径匹配，以支持 "/build"、"docs/*.tmp" 这类锚定规则
 		// 只有当 ignoreObj 存在时才检查
-		if ignoreObj != nil && ignoreObj.MatchesPath(entry.Name()) {
+		if ignoreObj != nil && ignoreObj.Match(childRel, isDirEntry(fullPath, entry)) {
 			continue
 		}
 
-		// 构建子文件的完整路径
-		fullPath := filepath.Join(path, entry.Name())
-
-		// 递归调用 (深度 + 1)
-		// 传递 rootPath 和 gitMap
-		// 传递 opts
-		childNode, err := scanDir(fullPath, rootPath, ignoreObj, depth+1, c, gitMap, opts)
+		// 获取文件或文件夹的基础信息 (跟随符号链接)
+		info, err := os.Stat(fullPath)
 		if err != nil {
 			continue // 遇到错误选择跳过而不是崩溃
 		}
 
-		// childNode 为 nil 时不添加
-		if childNode != nil {
-			node.Children = append(node.Children, childNode)
+		node := &model.Node{
+			Name:  info.Name(),
+			Path:  fullPath,
+			IsDir: info.IsDir(),
 		}
 
-		// 数量限制优化，提前跳出
-		if c.limitReached {
-			break
+		res.children = append(res.children, node)
+		if node.IsDir {
+			res.jobs = append(res.jobs, dirJob{node: node, relPath: childRel, ignore: ignoreObj})
 		}
 	}
-	return node, nil
+	return res
+}
+
+// isDirEntry 判断目录项是否为文件夹，符号链接会跟随到目标
+func isDirEntry(fullPath string, entry os.DirEntry) bool {
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(fullPath)
+		return err == nil && info.IsDir()
+	}
+	return entry.IsDir()
 }
 
 // 辅助函数DefaultOptions：生成默认配置
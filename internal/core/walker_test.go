@@ -0,0 +1,74 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// childNames 返回节点的名称列表
+func childNames(children []*model.Node) []string {
+	names := []string{}
+	for _, child := range children {
+		names = append(names, child.Name)
+	}
+	return names
+}
+
+func TestDirLoaderIgnores(t *testing.T) {
+	root := initRepo(t, map[string]string{
+		".gitignore":     "*.log\n",
+		"a/.gitignore":   "!keep.log\n",
+		"a/keep.log":     "keep",
+		"a/b/.gitignore": "*.tmp\n",
+		"a/b/c/keep.log": "keep",
+		"a/b/c/x.tmp":    "tmp",
+		"a/b/c/d/x.go":   "package x\n",
+		"other/keep.log": "ignored",
+	})
+	opts := DefaultOptions()
+	opts.Lazy = true
+	want := []string{"d", "keep.log"}
+
+	// 逐层展开：子文件夹使用读取父文件夹时记住的匹配器
+	loader := NewDirLoader(root, opts)
+	for _, rel := range []string{"a", "a/b"} {
+		if _, _, err := loader.Load(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := loader.ignores["a/b/c"]; !ok {
+		t.Error("loading a/b did not remember the matcher for a/b/c")
+	}
+	children, _, err := loader.Load(filepath.Join(root, "a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(children); !reflect.DeepEqual(got, want) {
+		t.Errorf("a/b/c expanded level by level = %v, want %v", got, want)
+	}
+
+	// 直接读取深层文件夹时从根目录开始叠加祖先的规则
+	children, _, err = NewDirLoader(root, opts).Load(filepath.Join(root, "a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(children); !reflect.DeepEqual(got, want) {
+		t.Errorf("a/b/c loaded directly = %v, want %v", got, want)
+	}
+	for _, child := range children {
+		if child.IsDir && (!child.Unloaded || !child.Collapsed) {
+			t.Errorf("%s should be unloaded and collapsed", child.Name)
+		}
+	}
+
+	children, _, err = loader.Load(filepath.Join(root, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := childNames(children); len(got) != 0 {
+		t.Errorf("other = %v, want keep.log ignored outside a/", got)
+	}
+}
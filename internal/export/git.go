@@ -0,0 +1,112 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// Git 标记的样式类别，TUI 与 SVG 共用同一套名称
+const (
+	GitClassModified = "git-mod"
+	GitClassAdded    = "git-add"
+	GitClassDeleted  = "git-del"
+	GitClassRenamed  = "git-ren"
+	GitClassType     = "git-type"
+	GitClassConflict = "git-conflict"
+	GitClassStaged   = "git-staged"
+	GitClassIgnored  = "git-ignored"
+)
+
+// GitMark 返回节点的 Git 标记，例如 " [M]"；无变化时返回空字符串
+// 同时存在已暂存与未暂存的改动时追加 "*"，例如 " [M*]"
+func GitMark(node *model.Node) string {
+	symbol := ""
+	switch node.GitStatus {
+	case model.GitModified:
+		symbol = "M"
+	case model.GitAdded:
+		symbol = "+"
+	case model.GitDeleted:
+		symbol = "-"
+	case model.GitRenamed:
+		symbol = "R"
+	case model.GitCopied:
+		symbol = "C"
+	case model.GitTypeChanged:
+		symbol = "T"
+	case model.GitConflict:
+		symbol = "U"
+	case model.GitIgnored:
+		symbol = "I"
+	default:
+		return ""
+	}
+
+	if node.IsPartiallyStaged() {
+		symbol += "*"
+	}
+	return " [" + symbol + "]"
+}
+
+// GitClass 返回节点 Git 标记的样式类别
+// 只存在于暂存区的改动使用单独的 "已暂存" 颜色，冲突与删除优先
+func GitClass(node *model.Node) string {
+	switch node.GitStatus {
+	case "":
+		return ""
+	case model.GitConflict:
+		return GitClassConflict
+	case model.GitDeleted:
+		return GitClassDeleted
+	case model.GitIgnored:
+		return GitClassIgnored
+	}
+
+	if node.IsStaged() {
+		return GitClassStaged
+	}
+
+	switch node.GitStatus {
+	case model.GitAdded:
+		return GitClassAdded
+	case model.GitRenamed, model.GitCopied:
+		return GitClassRenamed
+	case model.GitTypeChanged:
+		return GitClassType
+	}
+	return GitClassModified
+}
+
+// GitDetail 返回重命名/复制的来源说明，例如 " ← old/name.go"
+func GitDetail(node *model.Node) string {
+	if node.GitOrigPath == "" {
+		return ""
+	}
+	return " ← " + node.GitOrigPath
+}
+
+// LineCounts 返回行数变化的两个部分，例如 "+12" 与 "−3"；为 0 的部分返回空字符串
+func LineCounts(node *model.Node) (string, string) {
+	added, removed := "", ""
+	if node.LinesAdded > 0 {
+		added = fmt.Sprintf("+%d", node.LinesAdded)
+	}
+	if node.LinesRemoved > 0 {
+		removed = fmt.Sprintf("−%d", node.LinesRemoved)
+	}
+	return added, removed
+}
+
+// LineBadge 返回行数变化徽标，例如 " +12 −3"；没有行数变化时返回空字符串
+func LineBadge(node *model.Node) string {
+	added, removed := LineCounts(node)
+	badge := ""
+	if added != "" {
+		badge += " " + added
+	}
+	if removed != "" {
+		badge += " " + removed
+	}
+	return badge
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// JSONSchemaVersion 是 JSON 导出格式的版本号
+// 仅新增字段时保持不变，字段含义或结构发生不兼容变化时递增
+const JSONSchemaVersion = 1
+
+// JSONDocument 是 JSON 导出的顶层结构 (schema v1)
+//
+//	{
+//	  "schema_version": 1,
+//	  "root": { ...JSONNode }
+//	}
+type JSONDocument struct {
+	SchemaVersion int       `json:"schema_version"`
+	Root          *JSONNode `json:"root"`
+}
+
+// JSONNode 对应一个 model.Node
+// path 是相对于根目录、以 "/" 分隔的路径，根节点为 "."
+// git_status 为归纳后的状态码，git_index / git_worktree 为 porcelain 的 X / Y 两列
+// lines_added / lines_removed 为行数变化，文件夹为其子孙之和
+// ghost 表示文件已从磁盘删除，仅根据 Git 记录显示
+// 空值字段会被省略，children 仅在文件夹有子节点时出现
+type JSONNode struct {
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	IsDir      bool        `json:"is_dir"`
+	GitStatus  string      `json:"git_status,omitempty"`
+	GitIndex   string      `json:"git_index,omitempty"`
+	GitWork    string      `json:"git_worktree,omitempty"`
+	GitOrig    string      `json:"git_orig_path,omitempty"`
+	Added      int         `json:"lines_added,omitempty"`
+	Removed    int         `json:"lines_removed,omitempty"`
+	Ghost      bool        `json:"ghost,omitempty"`
+	Annotation string      `json:"annotation,omitempty"`
+	Hidden     bool        `json:"hidden"`
+	Collapsed  bool        `json:"collapsed"`
+	Children   []*JSONNode `json:"children,omitempty"`
+}
+
+// JSON 将树序列化为带缩进的 JSON 文档
+// all 为 false 时与文本导出使用相同的过滤规则；为 true 时导出全部节点 (包括隐藏与折叠的内容)
+func JSON(root *model.Node, opts Options, all bool) ([]byte, error) {
+	doc := JSONDocument{
+		SchemaVersion: JSONSchemaVersion,
+		Root:          buildJSONNode(root, root.Path, opts, all),
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// buildJSONNode 递归构建 JSON 节点
+func buildJSONNode(node *model.Node, rootPath string, opts Options, all bool) *JSONNode {
+	relPath, err := filepath.Rel(rootPath, node.Path)
+	if err != nil {
+		relPath = node.Name
+	}
+
+	jn := &JSONNode{
+		Name:       node.Name,
+		Path:       filepath.ToSlash(relPath),
+		IsDir:      node.IsDir,
+		GitStatus:  node.GitStatus,
+		GitIndex:   node.GitIndex,
+		GitWork:    node.GitWorktree,
+		GitOrig:    node.GitOrigPath,
+		Added:      node.LinesAdded,
+		Removed:    node.LinesRemoved,
+		Ghost:      node.Ghost,
+		Annotation: node.Annotation,
+		Hidden:     node.Hidden,
+		Collapsed:  node.Collapsed,
+	}
+
+	// 根节点永远展开，其余节点遵循与文本导出一致的展开规则
+	if !all && node.Path != rootPath && !opts.shouldExpand(node) {
+		return jn
+	}
+
+	children := node.Children
+	if !all {
+		children = opts.visibleChildren(children)
+	}
+	for _, child := range children {
+		jn.Children = append(jn.Children, buildJSONNode(child, rootPath, opts, all))
+	}
+	return jn
+}
@@ -0,0 +1,259 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// SVG 主题系统定义
+type Theme struct {
+	Name         string
+	BgColor      string // 背景色
+	TextColor    string // 普通文件名颜色
+	TreeColor    string // 树形线条颜色
+	FolderColor  string // 文件夹图标颜色
+	CommentColor string // 注释颜色
+	GitModColor  string // [M] 颜色
+	GitAddColor  string // [+] 颜色
+
+	GitDelColor      string // [-] 颜色
+	GitRenColor      string // [R] / [C] 颜色
+	GitTypeColor     string // [T] 颜色
+	GitConflictColor string // [U] 颜色
+	GitStagedColor   string // 仅暂存的改动
+	GitIgnoredColor  string // [I] 颜色
+}
+
+// 预设两套风格主题
+var (
+	// Dark: 基于 VSCode Dark
+	DarkTheme = Theme{
+		Name:         "Dark",
+		BgColor:      "#282a36",
+		TextColor:    "#f8f8f2",
+		TreeColor:    "#6272a4",
+		FolderColor:  "#8be9fd",
+		CommentColor: "#6272a4",
+		GitModColor:  "#f1fa8c", // Yellow
+		GitAddColor:  "#50fa7b", // Green
+
+		GitDelColor:      "#ff5555", // Red
+		GitRenColor:      "#8be9fd", // Cyan
+		GitTypeColor:     "#ff79c6", // Pink
+		GitConflictColor: "#ff5555", // Red
+		GitStagedColor:   "#bd93f9", // Purple
+		GitIgnoredColor:  "#6272a4", // Grey
+	}
+	// Light: 基于 GitHub Light
+	LightTheme = Theme{
+		Name:         "Light",
+		BgColor:      "#ffffff",
+		TextColor:    "#24292e",
+		TreeColor:    "#d1d5da", // Light grey for tree lines
+		FolderColor:  "#0366d6", // Blue
+		CommentColor: "#6a737d", // Grey
+		GitModColor:  "#b08800", // Dark Yellow
+		GitAddColor:  "#22863a", // Green
+
+		GitDelColor:      "#cb2431", // Red
+		GitRenColor:      "#005cc5", // Blue
+		GitTypeColor:     "#d03592", // Pink
+		GitConflictColor: "#cb2431", // Red
+		GitStagedColor:   "#6f42c1", // Purple
+		GitIgnoredColor:  "#959da5", // Grey
+	}
+)
+
+// ThemeByName 根据名称 (不区分大小写) 查找预设主题
+func ThemeByName(name string) (Theme, bool) {
+	switch strings.ToLower(name) {
+	case "dark":
+		return DarkTheme, true
+	case "light":
+		return LightTheme, true
+	}
+	return Theme{}, false
+}
+
+// SVG 负责生成带主题的 SVG 文档
+func SVG(root *model.Node, theme Theme, opts Options) string {
+	lineHeight := 24 // 增加行高，更宽松
+
+	// 1. 生成内容 (XML 格式)
+	content, lineCount, maxCharWidth := generateSVGContent(root, opts)
+
+	// 2. 计算画布
+	width := (maxCharWidth * 10) + 60 // 10px per char + padding
+	if width < 600 {
+		width = 600
+	} // 最小宽度
+	height := (lineCount * lineHeight) + 60
+
+	var sb strings.Builder
+	// Header
+	sb.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, width, height))
+	// Background
+	sb.WriteString(fmt.Sprintf(`<rect width="100%%" height="100%%" fill="%s" />`, theme.BgColor))
+
+	// 字体栈：显式声明中文字体
+	fontFamily := "'Consolas', 'Monaco', 'Microsoft YaHei', 'PingFang SC', 'WenQuanYi Micro Hei', monospace"
+
+	// Style
+	sb.WriteString(fmt.Sprintf(`<style>
+		text {
+			font-family: %s;
+			font-size: 14px;
+			white-space: pre;
+		}
+		.tree { fill: %s; }
+		.text { fill: %s; }
+		.folder { fill: %s; font-weight: bold; }
+		.comment { fill: %s; font-style: italic; }
+		.git-mod { fill: %s; font-weight: bold; }
+		.git-add { fill: %s; font-weight: bold; }
+		.git-del { fill: %s; font-weight: bold; text-decoration: line-through; }
+		.git-ren { fill: %s; font-weight: bold; }
+		.git-type { fill: %s; font-weight: bold; }
+		.git-conflict { fill: %s; font-weight: bold; }
+		.git-staged { fill: %s; font-weight: bold; }
+		.git-ignored { fill: %s; }
+		.lines-add { fill: %s; }
+		.lines-del { fill: %s; }
+	</style>`,
+		fontFamily,
+		theme.TreeColor, theme.TextColor, theme.FolderColor, theme.CommentColor, theme.GitModColor, theme.GitAddColor,
+		theme.GitDelColor, theme.GitRenColor, theme.GitTypeColor, theme.GitConflictColor, theme.GitStagedColor, theme.GitIgnoredColor,
+		theme.GitAddColor, theme.GitDelColor))
+
+	// Padding Container (Translate)
+	sb.WriteString(`<g transform="translate(30, 40)">`) // 左上角留白
+	sb.WriteString(content)
+	sb.WriteString(`</g></svg>`)
+
+	return sb.String()
+}
+
+// generateSVGContent 递归生成 SVG 内容
+func generateSVGContent(root *model.Node, opts Options) (string, int, int) {
+	var sb strings.Builder
+	lineIndex := 0
+	maxLen := 0
+
+	// 根节点，先递增行号
+	lineIndex++
+	sb.WriteString(fmt.Sprintf(`<text x="0" y="%d" class="folder">%s</text>`, lineIndex*24-24, escapeXML(root.Name)))
+	if len(root.Name) > maxLen {
+		maxLen = len(root.Name)
+	}
+
+	// 递归
+	childContent, childMax := writeSVGRecursive(root.Children, "", opts, &lineIndex)
+	sb.WriteString(childContent)
+
+	if childMax > maxLen {
+		maxLen = childMax
+	}
+	return sb.String(), lineIndex, maxLen
+}
+
+// writeSVGRecursive 递归生成 XML 标签
+func writeSVGRecursive(children []*model.Node, prefix string, opts Options, lineIndex *int) (string, int) {
+	var sb strings.Builder
+	maxLen := 0
+	lineHeight := 24
+
+	visibleChildren := opts.visibleChildren(children)
+
+	for i, child := range visibleChildren {
+		*lineIndex = *lineIndex + 1 // 先递增行号
+		currentY := (*lineIndex - 1) * lineHeight
+
+		isLast := i == len(visibleChildren)-1
+		connector := "├── "
+		if isLast {
+			connector = "└── "
+		}
+
+		// 开始一行
+		sb.WriteString(fmt.Sprintf(`<text x="0" y="%d">`, currentY))
+
+		// 1. 树形线条
+		sb.WriteString(fmt.Sprintf(`<tspan class="tree">%s%s</tspan>`, escapeXML(prefix), connector))
+
+		// 2. 图标 (可选，为了美观这里统一用箭头或留空)
+		icon := ""
+		if child.IsDir {
+			icon = "▼ "
+		}
+		if icon != "" {
+			sb.WriteString(fmt.Sprintf(`<tspan class="tree">%s</tspan>`, icon))
+		}
+
+		// 3. 文件名 (根据 Git 状态变色)
+		nameClass := "text"
+		if child.IsDir {
+			nameClass = "folder"
+		}
+		gitClass := GitClass(child)
+		if opts.GitMode && gitClass != "" {
+			nameClass = gitClass
+		}
+		sb.WriteString(fmt.Sprintf(`<tspan class="%s">%s</tspan>`, nameClass, escapeXML(child.Name)))
+
+		// 4. Git 标记与重命名来源 (仅在 Git 模式下)
+		gitMark := ""
+		if opts.GitMode {
+			gitMark = GitMark(child) + GitDetail(child)
+			if gitMark != "" {
+				sb.WriteString(fmt.Sprintf(`<tspan class="%s">%s</tspan>`, gitClass, escapeXML(gitMark)))
+			}
+
+			// 行数变化徽标
+			added, removed := LineCounts(child)
+			if added != "" {
+				sb.WriteString(fmt.Sprintf(`<tspan class="lines-add"> %s</tspan>`, added))
+			}
+			if removed != "" {
+				sb.WriteString(fmt.Sprintf(`<tspan class="lines-del"> %s</tspan>`, removed))
+			}
+			gitMark += LineBadge(child)
+		}
+
+		// 5. 注释
+		if child.Annotation != "" {
+			sb.WriteString(fmt.Sprintf(`<tspan class="comment">  # %s</tspan>`, escapeXML(child.Annotation)))
+		}
+
+		sb.WriteString(`</text>`)
+
+		// 计算粗略宽度
+		rowLen := len(prefix) + 4 + len(child.Name) + len(gitMark) + len(child.Annotation) + 5
+		if rowLen > maxLen {
+			maxLen = rowLen
+		}
+
+		// 递归子节点
+		if child.IsDir && len(child.Children) > 0 && opts.shouldExpand(child) {
+			childPrefix := prefix + "│   "
+			if isLast {
+				childPrefix = prefix + "    "
+			}
+			cContent, cMax := writeSVGRecursive(child.Children, childPrefix, opts, lineIndex)
+			sb.WriteString(cContent)
+			if cMax > maxLen {
+				maxLen = cMax
+			}
+		}
+	}
+	return sb.String(), maxLen
+}
+
+// escapeXML 转义 XML 特殊字符
+func escapeXML(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+)
+
+// Options 控制导出时的过滤与展开行为，与 TUI 当前所见保持一致
+type Options struct {
+	GitMode bool                   // 是否追加 Git 标记 (同时强制展开)
+	Expand  bool                   // 是否强制展开所有文件夹 (例如搜索过滤时)
+	Filter  func(*model.Node) bool // 额外的过滤条件，nil 表示不过滤
+}
+
+// shouldShow 判断节点是否需要导出：手动隐藏的节点永远不导出
+func (o Options) shouldShow(node *model.Node) bool {
+	if node.Hidden {
+		return false
+	}
+	if o.GitMode && !node.HasGitChanges() {
+		return false
+	}
+	if o.Filter != nil && !o.Filter(node) {
+		return false
+	}
+	return true
+}
+
+// shouldExpand 判断文件夹是否需要展开其子节点
+func (o Options) shouldExpand(node *model.Node) bool {
+	return !node.Collapsed || o.Expand || o.GitMode
+}
+
+// visibleChildren 预先过滤出需要导出的子节点
+func (o Options) visibleChildren(children []*model.Node) []*model.Node {
+	var visible []*model.Node
+	for _, child := range children {
+		if o.shouldShow(child) {
+			visible = append(visible, child)
+		}
+	}
+	return visible
+}
+
+// Text 生成纯文本树
+func Text(root *model.Node, opts Options) string {
+	var sb strings.Builder
+	// 根目录不带前缀
+	sb.WriteString(fmt.Sprintf("%s\n", root.Name))
+
+	// 递归生成子节点，不需要 cursor 逻辑，只需要纯粹的遍历
+	writeChildrenText(&sb, root.Children, "", opts)
+	return sb.String()
+}
+
+// Markdown 生成用 markdown 代码块包裹的文本树，方便直接粘贴到文档
+func Markdown(root *model.Node, opts Options) string {
+	return fmt.Sprintf("```text\n%s```", Text(root, opts))
+}
+
+// writeChildrenText 递归生成纯文本内容
+func writeChildrenText(sb *strings.Builder, children []*model.Node, prefix string, opts Options) {
+	visibleChildren := opts.visibleChildren(children)
+
+	for i, child := range visibleChildren {
+		isLast := i == len(visibleChildren)-1
+
+		connector := "├── "
+		if isLast {
+			connector = "└── "
+		}
+
+		// 根据 GitMode 决定是否追加 Git 标记 (以及重命名来源和行数变化)
+		gitSuffix := ""
+		if opts.GitMode {
+			gitSuffix = GitMark(child) + GitDetail(child) + LineBadge(child)
+		}
+
+		// 输出行：前缀 + 连接线 + 文件名 + [Git标记] + [注释]
+		line := fmt.Sprintf("%s%s%s%s", prefix, connector, child.Name, gitSuffix)
+
+		if child.Annotation != "" {
+			// 导出时的注释格式，用空格对齐
+			line += fmt.Sprintf("  # %s", child.Annotation)
+		}
+		sb.WriteString(line + "\n")
+
+		// 递归处理子文件夹
+		if child.IsDir && len(child.Children) > 0 && opts.shouldExpand(child) {
+			childPrefix := prefix + "│   "
+			if isLast {
+				childPrefix = prefix + "    "
+			}
+			writeChildrenText(sb, child.Children, childPrefix, opts)
+		}
+	}
+}
@@ -1,5 +1,17 @@
 package model
 
+// Git 状态码 (Node.GitStatus)，由暂存区与工作区两列状态归纳而来
+const (
+	GitModified    = "M" // 修改
+	GitAdded       = "A" // 新增/未追踪
+	GitDeleted     = "D" // 删除
+	GitRenamed     = "R" // 重命名
+	GitCopied      = "C" // 复制
+	GitTypeChanged = "T" // 类型变化 (例如文件变为符号链接)
+	GitConflict    = "U" // 合并冲突
+	GitIgnored     = "!" // 已忽略 (仅 Force 模式下可见)
+)
+
 //Node 是一个节点，代表目录树中的一个文件或文件夹
 type Node struct {
 	Name     string  //文件名，e.g. "main.go"
@@ -13,6 +25,42 @@ type Node struct {
 	Annotation string //用户注释
 
 	// Git 状态
-	// "" = 无变化, "M" = 修改, "A" = 新增/未追踪, "?" = 未知
-	GitStatus string
+	// "" = 无变化，其余取值见上方的 Git 状态码常量
+	GitStatus   string
+	GitIndex    string // 暂存区状态 (porcelain 的 X 列)，"" = 无变化
+	GitWorktree string // 工作区状态 (porcelain 的 Y 列)，"" = 无变化
+	GitOrigPath string // 重命名/复制的来源路径 (相对根目录)
+
+	// 行数变化 (git diff --numstat)，文件夹为其子孙之和
+	LinesAdded   int
+	LinesRemoved int
+
+	// 幽灵节点：文件已从磁盘删除，仅根据 Git 记录显示
+	Ghost bool
+
+	// 懒加载模式下尚未读取子节点的文件夹 (总是折叠，展开时再读取)
+	Unloaded bool
+}
+
+// HasGitChanges 递归检查节点自身或其子孙是否有 Git 变更 (被忽略不算变更)
+func (n *Node) HasGitChanges() bool {
+	if n.GitStatus != "" && n.GitStatus != GitIgnored {
+		return true
+	}
+	for _, child := range n.Children {
+		if child.HasGitChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaged 判断变更是否全部位于暂存区 (工作区没有额外改动)
+func (n *Node) IsStaged() bool {
+	return n.GitIndex != "" && n.GitIndex != "?" && n.GitIndex != "!" && n.GitWorktree == ""
+}
+
+// IsPartiallyStaged 判断是否同时存在已暂存与未暂存的改动
+func (n *Node) IsPartiallyStaged() bool {
+	return n.GitIndex != "" && n.GitIndex != "?" && n.GitIndex != "!" && n.GitWorktree != "" && n.GitStatus != GitConflict
 }
@@ -1,15 +1,19 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"os" // 用于获取当前工作目录以保存配置
+	"os"
 	"runtime"
 	"strings"
 	"time" // 用于 Tick
 
 	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/export"
 	"github.com/DoraleCitrus/gentr/internal/model"
-	"github.com/atotto/clipboard"                // 剪贴板库
+	"github.com/atotto/clipboard" // 剪贴板库
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput" // 输入框组件
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -35,12 +39,29 @@ var (
 	annotationStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("246")).Italic(true)
 	// 搜索匹配的高亮样式 (黄色加粗)
 	searchMatchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00")).Bold(true)
+	// 内容搜索匹配数：青色
+	grepCountStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#88C0D0"))
 
 	// Git 样式
 	// 黄色表示修改
 	gitModifiedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#EBCB8B")).Bold(true)
 	// 绿色表示新增
 	gitAddedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#A3BE8C")).Bold(true)
+	// 红色 + 删除线表示删除
+	gitDeletedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#BF616A")).Bold(true).Strikethrough(true)
+	// 青色表示重命名/复制
+	gitRenamedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#88C0D0")).Bold(true)
+	// 洋红表示类型变化
+	gitTypeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#B48EAD")).Bold(true)
+	// 红底表示冲突
+	gitConflictStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color("#BF616A")).Bold(true)
+	// 紫色表示改动已全部暂存
+	gitStagedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#8F9FD8")).Bold(true)
+	// 暗灰表示已忽略
+	gitIgnoredStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	// 行数变化徽标：绿色新增，红色删除
+	linesAddedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#A3BE8C"))
+	linesRemovedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#BF616A"))
 	// Git模式下的状态栏 (橙色背景)
 	gitStatusBarStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color("#D08770")).Padding(0, 1).Bold(true)
 
@@ -48,44 +69,6 @@ var (
 	updateBannerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#005faff")).Padding(0, 1).Bold(true)
 )
 
-// SVG 主题系统定义
-type Theme struct {
-	Name         string
-	BgColor      string // 背景色
-	TextColor    string // 普通文件名颜色
-	TreeColor    string // 树形线条颜色
-	FolderColor  string // 文件夹图标颜色
-	CommentColor string // 注释颜色
-	GitModColor  string // [M] 颜色
-	GitAddColor  string // [+] 颜色
-}
-
-// 预设两套风格主题
-var (
-	// Dark: 基于 VSCode Dark
-	DarkTheme = Theme{
-		Name:         "Dark",
-		BgColor:      "#282a36",
-		TextColor:    "#f8f8f2",
-		TreeColor:    "#6272a4",
-		FolderColor:  "#8be9fd",
-		CommentColor: "#6272a4",
-		GitModColor:  "#f1fa8c", // Yellow
-		GitAddColor:  "#50fa7b", // Green
-	}
-	// Light: 基于 GitHub Light
-	LightTheme = Theme{
-		Name:         "Light",
-		BgColor:      "#ffffff",
-		TextColor:    "#24292e",
-		TreeColor:    "#d1d5da", // Light grey for tree lines
-		FolderColor:  "#0366d6", // Blue
-		CommentColor: "#6a737d", // Grey
-		GitModColor:  "#b08800", // Dark Yellow
-		GitAddColor:  "#22863a", // Green
-	}
-)
-
 // 定义防抖消息，携带版本号
 type SaveMsg struct {
 	Tag int
@@ -99,6 +82,7 @@ type CheckUpdateMsg struct {
 
 // MainModel 是 TUI 的状态容器
 type MainModel struct {
+	RootPath     string      // 扫描的根目录 (绝对路径)，.gentr.json 保存在这里
 	RootNode     *model.Node // 之前的扫描结果
 	Cursor       int         // 记录当前光标在第几行
 	ScrollOffset int         // 滚动偏移量
@@ -108,11 +92,65 @@ type MainModel struct {
 	Width  int
 	Height int
 
-	LimitWarning bool // 警告标记
+	Limits core.LimitInfo // 扫描被安全限制截断的位置，用于警告条
 
 	// 用于在状态栏显示临时消息
 	StatusMsg string
 
+	// 最近一次保存 .gentr.json 的错误，退出时由 main 打印
+	SaveErr error
+
+	// 加载 .gentr.json 时未能应用到树上的条目 (保存时写回)
+	Config *core.ConfigState
+
+	// 失效注释面板
+	OrphanMode   bool
+	OrphanCursor int
+	RemapInput   textinput.Model
+	RemapMode    bool
+
+	// 导航模式的按键绑定 (可在用户配置文件中修改)
+	Keys KeyMap
+
+	// 帮助面板 (?) 与命令面板 (:)
+	HelpMode      bool
+	HelpScroll    int
+	PaletteMode   bool
+	PaletteInput  textinput.Model
+	PaletteCursor int
+
+	// 子节点的排序方式 (core.SortModes)，空为按文件名
+	SortMode string
+
+	// 跳转到路径的输入框
+	GotoInput textinput.Model
+	GotoMode  bool
+
+	// 配置了 Top 序列 (例如 gg) 时，按下第一个键后等待第二个键
+	pendingG     bool
+	pendingKey   tea.KeyMsg // 等待中的按键，超时后按单键处理
+	replayingKey bool       // 正在按单键处理 pendingKey，不再检查序列
+	gTag         int
+
+	// 上一次鼠标单击的时间与行号，用于识别双击
+	lastClick    time.Time
+	lastClickRow int
+
+	// 撤销/重做历史 (保存在用户缓存目录中)
+	History *core.History
+
+	// 多选：批量隐藏、折叠、注释、删除、复制路径
+	Selected     map[*model.Node]bool
+	selectAnchor *model.Node // 范围选择的起点 (最近一次切换的节点)
+
+	// 文件操作：FileOp 非空时显示路径输入框 (删除时为确认提示)
+	FileOp     string
+	FileInput  textinput.Model
+	fileTarget *model.Node // 被操作的节点 (新建时为 nil)
+
+	// 等待确认的项目命令 (来自 .gentr.json，尚未被允许运行)
+	confirmCommand *core.Command
+
 	// 输入框相关状态
 	TextInput textinput.Model // 输入框组件
 	InputMode bool            // 是否处于编辑模式
@@ -120,6 +158,9 @@ type MainModel struct {
 	// 搜索相关状态
 	SearchInput textinput.Model
 	SearchMode  bool
+	RankedMode  bool         // 按得分排序的扁平结果列表 (搜索时按 Tab 切换)
+	RankCursor  int          // 结果列表中的光标
+	search      *searchState // 模糊匹配结果缓存
 
 	// 防抖计数器 (版本号)
 	SaveTag int
@@ -127,6 +168,35 @@ type MainModel struct {
 	// Git 模式开关
 	GitMode bool
 
+	// 右侧预览分栏
+	PreviewMode bool
+	preview     *previewCache
+
+	// 内容搜索：Grep 非 nil 时只显示内容匹配的文件
+	GrepInput  textinput.Model
+	GrepMode   bool
+	Grep       *grepState
+	grepTag    int                // 搜索版本号，丢弃过期的结果
+	grepCancel context.CancelFunc // 停止正在进行的搜索
+
+	// 对比模式：非空时 Git 状态来自 git diff <DiffRef>
+	DiffRef  string
+	RefInput textinput.Model
+	RefMode  bool
+
+	// 扫描选项，用于重新加载 Git 状态等需要再次访问磁盘的操作
+	WalkOpts core.WalkOptions
+
+	// 懒加载模式下正在后台读取的文件夹，以及加载指示的动画
+	dirLoader  *core.DirLoader
+	loading    map[*model.Node]bool
+	Spinner    spinner.Model
+	spinning   bool
+	lazyGitTag int // 读取文件夹后延时刷新 Git 状态的计数器
+
+	// 合并用户配置、.gentr.json 与命令行参数后的设置 (导出目录、主题、更新检查)
+	Settings core.ResolvedSettings
+
 	// 版本相关字段
 	CurrentVersion  string
 	UpdateAvailable bool
@@ -134,7 +204,7 @@ type MainModel struct {
 }
 
 // InitialModel 初始化状态
-func InitialModel(root *model.Node, limitReached bool, currentVersion string) MainModel {
+func InitialModel(rootPath string, root *model.Node, limits core.LimitInfo, currentVersion string) MainModel {
 	// 初始化输入框
 	ti := textinput.New()
 	ti.Placeholder = "Type comment..."
@@ -144,33 +214,96 @@ func InitialModel(root *model.Node, limitReached bool, currentVersion string) Ma
 
 	// 初始化搜索输入框
 	si := textinput.New()
-	si.Placeholder = "Search files..."
+	si.Placeholder = "Search files... (ext:go path:cmd/** git:M ann:todo)"
 	si.Prompt = "/ "
-	si.CharLimit = 50
+	si.CharLimit = 200
 	si.Width = 50
 
+	// 初始化对比 ref 输入框
+	ri := textinput.New()
+	ri.Placeholder = "main, HEAD~3, main...HEAD (empty = working tree)"
+	ri.Prompt = "ref: "
+	ri.CharLimit = 100
+	ri.Width = 50
+
+	// 初始化内容搜索输入框
+	gi := textinput.New()
+	gi.Placeholder = "text in files (re:<regex> for regular expressions)"
+	gi.Prompt = "grep: "
+	gi.CharLimit = 200
+	gi.Width = 50
+
+	// 初始化失效注释重新关联的路径输入框
+	mi := textinput.New()
+	mi.Placeholder = "new/relative/path"
+	mi.Prompt = "path: "
+	mi.CharLimit = 256
+	mi.Width = 50
+
+	// 初始化文件操作路径输入框
+	fi := textinput.New()
+	fi.Placeholder = "relative/path"
+	fi.Prompt = "path: "
+	fi.CharLimit = 256
+	fi.Width = 50
+
+	// 初始化跳转路径输入框
+	gt := textinput.New()
+	gt.Placeholder = "path/to/file (fuzzy)"
+	gt.Prompt = "Go to: "
+	gt.CharLimit = 256
+	gt.Width = 50
+
+	// 初始化懒加载的加载指示
+	sp := spinner.New()
+	sp.Spinner = spinner.MiniDot
+
+	// 初始化命令面板输入框
+	pi := textinput.New()
+	pi.Placeholder = "type to filter commands"
+	pi.Prompt = ": "
+	pi.CharLimit = 64
+	pi.Width = 50
+
 	return MainModel{
+		RootPath:       rootPath,
 		RootNode:       root,
 		Cursor:         0,
 		ScrollOffset:   0,
 		Quitting:       false,
 		Width:          80,
 		Height:         24,
-		LimitWarning:   limitReached,   // 注入状态
-		StatusMsg:      "",             // 初始化为空
-		TextInput:      ti,             // 注入输入框
-		InputMode:      false,          // 默认关闭
-		SearchInput:    si,             // 注入搜索框
-		SearchMode:     false,          // 默认关闭
-		SaveTag:        0,              // 防抖计数器初始化
-		GitMode:        false,          // 默认关闭 Git 模式
-		CurrentVersion: currentVersion, // 保存当前版本
+		Limits:         limits,          // 注入状态
+		StatusMsg:      "",              // 初始化为空
+		TextInput:      ti,              // 注入输入框
+		InputMode:      false,           // 默认关闭
+		SearchInput:    si,              // 注入搜索框
+		SearchMode:     false,           // 默认关闭
+		search:         &searchState{},  // 搜索结果缓存
+		GrepInput:      gi,              // 注入内容搜索输入框
+		preview:        &previewCache{}, // 预览缓存
+		SaveTag:        0,               // 防抖计数器初始化
+		GitMode:        false,           // 默认关闭 Git 模式
+		RefInput:       ri,              // 注入对比 ref 输入框
+		RemapInput:     mi,              // 注入失效注释路径输入框
+		FileInput:      fi,              // 注入文件操作路径输入框
+		GotoInput:      gt,              // 注入跳转路径输入框
+		PaletteInput:   pi,              // 注入命令面板输入框
+		Keys:           DefaultKeyMap(), // 默认按键绑定
+		Spinner:        sp,              // 懒加载的加载指示
+		CurrentVersion: currentVersion,  // 保存当前版本
+		History:        core.LoadHistory(rootPath),
+		Settings:       core.ResolveSettings(core.Settings{}, core.Settings{}),
+		loading:        make(map[*model.Node]bool),
 	}
 }
 
 // Init 是程序启动时执行的初始化方法
 func (m MainModel) Init() tea.Cmd {
-	// 触发异步检查更新
+	// 触发异步检查更新 (可以在配置中关闭)
+	if !m.Settings.UpdateCheck {
+		return nil
+	}
 	return m.checkUpdateCmd
 }
 
@@ -187,20 +320,29 @@ func (m MainModel) checkUpdateCmd() tea.Msg {
 	}
 }
 
-// viewportHeight 计算用于显示文件树的视口高度
-func (m MainModel) viewportHeight() int {
+// headerHeight 计算文件树上方固定行的数量 (更新横幅、警告条、标题)
+func (m MainModel) headerHeight() int {
 	headerHeight := 1 // "Project: ..."
 	if m.UpdateAvailable {
 		headerHeight++
 	}
-	if m.LimitWarning {
+	if m.Limits.Reached() {
 		headerHeight++
 	}
+	return headerHeight
+}
+
+// viewportHeight 计算用于显示文件树的视口高度
+func (m MainModel) viewportHeight() int {
+	headerHeight := m.headerHeight()
 
 	footerHeight := 3 // Status bar + Help (approx)
-	if m.InputMode || m.SearchMode {
+	if m.InputMode || m.SearchMode || m.RefMode || m.RemapMode || m.GrepMode || m.GotoMode || m.PaletteMode || m.FileOp != "" || m.confirmCommand != nil {
 		footerHeight = 4 // Input box + hint (approx)
 	}
+	if m.Grep != nil {
+		footerHeight += grepPreviewLines + 1 // 匹配行预览
+	}
 
 	h := m.Height - headerHeight - footerHeight
 	if h < 1 {
@@ -244,31 +386,89 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// 处理对比 ref 的加载结果
+	if msg, ok := msg.(gitRefreshMsg); ok {
+		return m.handleGitRefresh(msg), nil
+	}
+
+	// 内容搜索结果
+	if grepMsg, ok := msg.(grepDoneMsg); ok {
+		return m.handleGrepDone(grepMsg), nil
+	}
+
+	// 单独的 g：按单键处理
+	if gMsg, ok := msg.(pendingGMsg); ok {
+		return m.handlePendingG(gMsg)
+	}
+
+	// 懒加载：文件夹的读取结果与加载指示的动画 (在任何模式下都要处理)
+	if loaded, ok := msg.(dirLoadedMsg); ok {
+		return m.handleDirLoaded(loaded)
+	}
+	if lazyGit, ok := msg.(lazyGitMsg); ok {
+		return m.handleLazyGit(lazyGit)
+	}
+	if tick, ok := msg.(spinner.TickMsg); ok {
+		return m.updateSpinner(tick)
+	}
+
+	// 外部程序退出，TUI 已恢复
+	if execMsg, ok := msg.(execDoneMsg); ok {
+		return m.handleExecDone(execMsg)
+	}
+
+	// 对比 ref 输入模式
+	if m.RefMode {
+		return m.updateRefMode(msg)
+	}
+
+	// 内容搜索输入模式
+	if m.GrepMode {
+		return m.updateGrepMode(msg)
+	}
+
+	// 帮助面板与命令面板
+	if m.HelpMode {
+		return m.updateHelpMode(msg)
+	}
+	if m.PaletteMode {
+		return m.updatePaletteMode(msg)
+	}
+
+	// 跳转到路径输入框
+	if m.GotoMode {
+		return m.updateGotoMode(msg)
+	}
+
+	// 文件操作输入框 / 删除确认
+	if m.FileOp != "" {
+		return m.updateFileOp(msg)
+	}
+
+	// 运行项目命令前的确认
+	if m.confirmCommand != nil {
+		return m.updateConfirmCommand(msg)
+	}
+
+	// 失效注释面板
+	if m.RemapMode {
+		return m.updateRemapMode(msg)
+	}
+	if m.OrphanMode {
+		return m.updateOrphanMode(msg)
+	}
+
 	// 搜索模式优先处理
 	if m.SearchMode {
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "enter", "esc":
-				// 退出搜索输入
-				// Esc 清空并退出，Enter 仅退出输入焦点但保留过滤结果
-				if msg.String() == "esc" {
-					m.SearchInput.SetValue("") // 清空搜索
-				}
-				m.SearchMode = false
-				m.Cursor = 0 // 退出搜索时重置光标防止越界
-				m.ScrollOffset = 0
-				return m, nil
-			}
-		}
-		// 更新搜索输入框
-		var siCmd tea.Cmd
-		m.SearchInput, siCmd = m.SearchInput.Update(msg)
+		return m.updateSearchMode(msg)
+	}
 
-		// 每次按键后，搜索词变了，树的结构就变了，光标必须重置，防止越界
-		m.Cursor = 0
-		m.ScrollOffset = 0
-		return m, siCmd
+	// 导航模式下响应鼠标
+	if mouseMsg, ok := msg.(tea.MouseMsg); ok {
+		if m.InputMode {
+			return m, nil
+		}
+		return m.updateMouse(mouseMsg)
 	}
 
 	// 区分 输入模式/导航模式
@@ -279,14 +479,27 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				// 保存注释
+				// 有多选时批量设置注释
+				m.InputMode = false
+				m.StatusMsg = "Comment saved!"
+				before := m.snapshot()
+				if nodes := m.selectedNodes(); len(nodes) > 0 {
+					for _, node := range nodes {
+						node.Annotation = m.TextInput.Value()
+					}
+					m.StatusMsg = fmt.Sprintf("Comment saved for %d items", len(nodes))
+					m.recordState("Edit comments", before)
+					m.invalidateSearch() // ann: 条件依赖注释
+					return m, m.triggerDebouncedSave()
+				}
 				idx := 0
 				node := m.getNodeAtCursor(m.RootNode.Children, &idx)
 				if node != nil {
 					node.Annotation = m.TextInput.Value()
+					m.recordState("Edit comment on "+node.Name, before)
+					m.invalidateSearch()
 					cmd = m.triggerDebouncedSave() // 使用防抖保存
 				}
-				m.InputMode = false
-				m.StatusMsg = "Comment saved!"
 				return m, cmd
 
 			case "esc":
@@ -304,97 +517,187 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// 导航模式
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
-			switch msg.String() {
-			// 按 q 或 Ctrl+C 退出程序
-			case "q", "ctrl+c":
-				m.Quitting = true
-				// 退出前强制立即保存一次，防止防抖还没触发就退出了
-				m.saveStateImmediate()
-				// 直接 Quit，屏幕恢复由 WithAltScreen 接管
-				return m, tea.Quit
+			// Ctrl+C 总是退出，不受按键配置影响
+			if msg.String() == "ctrl+c" {
+				return m.quit()
+			}
 
-			// 向上移动光标
-			case "up", "k":
-				if m.Cursor > 0 {
-					m.Cursor--
-					m.StatusMsg = "" // 移动光标时清除提示消息
-					if m.Cursor < m.ScrollOffset {
-						m.ScrollOffset = m.Cursor
-					}
+			// Top 中的连按 (默认为 gg)
+			if seq := m.Keys.topSequence(); seq != "" && !m.replayingKey {
+				if msg.String() == seq {
+					return m.pressG(msg)
 				}
+				if m.pendingG {
+					// 其它按键打断了连按：先执行等待中的单键操作，再处理这个按键
+					next, flushed := m.flushPendingKey()
+					next, cmd := next.Update(msg)
+					return next, tea.Batch(flushed, cmd)
+				}
+			}
+
+			// 按键绑定见 KeyMap，可以在用户配置文件中修改
+			switch {
+			// 退出程序
+			case key.Matches(msg, m.Keys.Quit):
+				return m.quit()
+
+			// 向上移动光标
+			case key.Matches(msg, m.Keys.Up):
+				m.moveCursor(-1)
 
 			// 向下移动光标
-			case "down", "j":
-				// 限制光标不能超过文件树的总行数
-				// 我们需要计算一下当前可见的总节点数
-				totalNodes := m.countVisibleNodes(m.RootNode.Children)
-				if m.Cursor < totalNodes-1 {
-					m.Cursor++
-					m.StatusMsg = "" // 移动光标时清除提示消息
-					vpHeight := m.viewportHeight()
-					if m.Cursor >= m.ScrollOffset+vpHeight {
-						m.ScrollOffset = m.Cursor - vpHeight + 1
-					}
-				}
+			case key.Matches(msg, m.Keys.Down):
+				m.moveCursor(1)
+
+			// 翻页
+			case key.Matches(msg, m.Keys.PageUp):
+				m.moveCursor(-m.viewportHeight())
+			case key.Matches(msg, m.Keys.PageDown):
+				m.moveCursor(m.viewportHeight())
+
+			// 跳到顶部 / 底部
+			case key.Matches(msg, m.Keys.Top):
+				m.setCursor(0)
+			case key.Matches(msg, m.Keys.Bottom):
+				m.setCursor(m.countVisibleNodes(m.RootNode.Children) - 1)
+
+			// 'h' 跳到父文件夹，'l' 进入文件夹 (折叠时先展开)
+			case key.Matches(msg, m.Keys.Parent):
+				m.jumpToParent()
+			case key.Matches(msg, m.Keys.Child):
+				return m.jumpToChild()
+
+			// ']' / '[' 跳到下一个 / 上一个同级节点
+			case key.Matches(msg, m.Keys.NextSibling):
+				m.jumpToSibling(1)
+			case key.Matches(msg, m.Keys.PrevSibling):
+				m.jumpToSibling(-1)
+
+			// '+' / '-' 递归展开 / 折叠光标所在文件夹的整个子树
+			case key.Matches(msg, m.Keys.ExpandAll):
+				return m.setCollapsedRecursive(false, false)
+			case key.Matches(msg, m.Keys.CollapseAll):
+				return m.setCollapsedRecursive(true, false)
+
+			// '?' 显示按键帮助，':' 打开命令面板
+			case key.Matches(msg, m.Keys.Help):
+				return m.openHelp()
+			case key.Matches(msg, m.Keys.Palette):
+				return m.openPalette()
+
+			// Ctrl+G 输入路径并跳转 (自动展开祖先文件夹)
+			case key.Matches(msg, m.Keys.GoTo):
+				return m.openGotoInput()
+
+			// 'v' 切换光标所在节点的选中状态，'x' 切换后移到下一行
+			case key.Matches(msg, m.Keys.Select):
+				m = m.toggleSelect()
+			case key.Matches(msg, m.Keys.SelectDown):
+				m = m.toggleSelect()
+				m.moveCursor(1)
+
+			// 'V' 选中从上一次切换的节点到光标之间的范围
+			case key.Matches(msg, m.Keys.SelectRange):
+				m = m.selectRange()
+
+			// '*' 选中当前过滤条件命中的所有节点
+			case key.Matches(msg, m.Keys.SelectMatching):
+				m = m.selectMatching()
+
+			// 'u' 撤销，Ctrl+R 重做
+			case key.Matches(msg, m.Keys.Undo):
+				return m.undo(false)
+			case key.Matches(msg, m.Keys.Redo):
+				return m.undo(true)
+
+			// 'Y' 复制选中节点 (没有选择时为光标所在节点) 的相对路径
+			case key.Matches(msg, m.Keys.CopyPaths):
+				m = m.copySelectedPaths()
+				return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
 
-			// 空格键折叠/展开
-			case " ":
+			// 空格键折叠/展开 (有多选时作用于所有选中的文件夹)
+			case key.Matches(msg, m.Keys.Fold):
+				before := m.snapshot()
+				if len(m.Selected) > 0 {
+					m = m.bulkToggleCollapsed()
+					m.recordState("Collapse/expand selection", before)
+					load := m.loadExpanded()
+					return m, tea.Batch(m.triggerDebouncedSave(), load)
+				}
 				idx := 0
 				// 传入 idx 指针，在递归中寻找当前光标对应的节点
 				// 如果发生状态改变，触发保存
 				if m.toggleNode(m.RootNode.Children, &idx) {
-					cmd = m.triggerDebouncedSave() // 使用防抖
+					m.recordState("Collapse/expand folder", before)
+					load := m.loadExpanded()
+					cmd = tea.Batch(m.triggerDebouncedSave(), load) // 使用防抖
 				}
 
-			// 回车键隐藏/显示
-			case "enter":
+			// 回车键隐藏/显示 (有多选时作用于所有选中的节点)
+			case key.Matches(msg, m.Keys.Hide):
+				before := m.snapshot()
+				if len(m.Selected) > 0 {
+					m = m.bulkToggleHidden()
+					m.recordState("Hide/show selection", before)
+					return m, m.triggerDebouncedSave()
+				}
 				idx := 0
 				// 如果发生状态改变，触发保存
 				if m.toggleHidden(m.RootNode.Children, &idx) {
+					m.recordState("Hide/show", before)
 					cmd = m.triggerDebouncedSave() // 使用防抖
 				}
 
-			// 'c' 键复制功能
-			case "c":
-				output := m.generateTreeOutput()
-				// 使用 markdown 代码块包裹，方便直接粘贴到文档
-				finalText := fmt.Sprintf("```text\n%s```", output)
-
-				err := clipboard.WriteAll(finalText)
-				if err != nil {
-					m.StatusMsg = "Error copying to clipboard!"
-				} else {
-					m.StatusMsg = "Copied to clipboard!"
-				}
-				// 返回一个空的 Tick 强制触发 View 刷新以显示 StatusMsg
-				return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
-
-			// 's' 键保存为文本文件
-			case "s":
-				output := m.generateTreeOutput()
-				filename := "gentr_output.txt"
-				err := os.WriteFile(filename, []byte(output), 0644)
-				if err != nil {
-					m.StatusMsg = "Error saving file: " + err.Error()
-				} else {
-					m.StatusMsg = fmt.Sprintf("Saved to %s", filename)
-				}
-				return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
-
-			// 'p' 键保存两套主题的 SVG 图片
-			case "p":
-				err1 := m.saveThemeSVG(DarkTheme, "gentr_dark.svg")
-				err2 := m.saveThemeSVG(LightTheme, "gentr_light.svg")
+			// 'c' 键复制到剪贴板，'s' / 'p' / 'J' 保存为文本 / SVG / JSON 文件
+			case key.Matches(msg, m.Keys.CopyTree):
+				return m.copyTree()
+			case key.Matches(msg, m.Keys.SaveText):
+				return m.saveText()
+			case key.Matches(msg, m.Keys.SaveSVG):
+				return m.saveSVG()
+			case key.Matches(msg, m.Keys.SaveJSON):
+				return m.saveJSON()
+
+			// Tab 键切换右侧预览分栏
+			case key.Matches(msg, m.Keys.Preview):
+				m = m.togglePreview()
+				return m, nil
 
-				if err1 != nil || err2 != nil {
-					m.StatusMsg = "Error saving SVG!"
-				} else {
-					m.StatusMsg = "Saved gentr_dark.svg & gentr_light.svg"
+			// 'F' 键搜索文件内容
+			case key.Matches(msg, m.Keys.Grep):
+				return m.openGrepInput()
+
+			// 'O' 键打开失效注释面板
+			case key.Matches(msg, m.Keys.Orphans):
+				return m.openOrphanPanel()
+
+			// 'e' 键用 $VISUAL / $EDITOR 打开光标所在的文件
+			case key.Matches(msg, m.Keys.Edit):
+				return m.openInEditor()
+
+			// '!' 键在光标所在的文件夹中打开 shell
+			case key.Matches(msg, m.Keys.Shell):
+				return m.openShell()
+
+			// 文件操作：新建、重命名、移动、复制、删除 (移到回收目录)
+			case key.Matches(msg, m.Keys.Create):
+				return m.openFileOp(fileOpCreate)
+			case key.Matches(msg, m.Keys.Rename):
+				return m.openFileOp(fileOpRename)
+			case key.Matches(msg, m.Keys.Move):
+				return m.openFileOp(fileOpMove)
+			case key.Matches(msg, m.Keys.Copy):
+				return m.openFileOp(fileOpCopy)
+			case key.Matches(msg, m.Keys.Delete):
+				return m.openFileOp(fileOpDelete)
+
+			// 按 'i' 进入编辑模式 (有多选时批量设置注释)
+			case key.Matches(msg, m.Keys.Comment):
+				if len(m.Selected) > 0 {
+					m.InputMode = true
+					m.TextInput.SetValue(m.commonAnnotation())
+					return m, textinput.Blink
 				}
-				return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
-
-			// 按 'i' 进入编辑模式
-			case "i":
 				idx := 0
 				node := m.getNodeAtCursor(m.RootNode.Children, &idx)
 				if node != nil {
@@ -406,18 +709,30 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 
 			// 按 '/' 进入搜索模式
-			case "/":
-				m.SearchMode = true
-				m.SearchInput.Focus()
-				return m, textinput.Blink
+			case key.Matches(msg, m.Keys.Search):
+				return m.openSearch()
 
 			// 在导航模式按 Esc 清空搜索结果，也退出 Git 模式
-			case "esc":
+			// 有多选时只清空选择
+			case key.Matches(msg, m.Keys.Clear):
+				if len(m.Selected) > 0 {
+					m = m.clearSelection()
+					m.StatusMsg = "Selection cleared"
+					return m, nil
+				}
 				if m.SearchInput.Value() != "" {
 					m.SearchInput.SetValue("")
 					m.Cursor = 0 // 重置光标
 					m.ScrollOffset = 0
 				}
+				// 退出内容搜索
+				if m.Grep != nil {
+					m = m.clearGrep()
+					m.StatusMsg = "Content search cleared"
+				} else if m.cancelGrep() {
+					m.grepTag++
+					m.StatusMsg = "Content search cancelled"
+				}
 				// 退出 Git 模式
 				if m.GitMode {
 					m.GitMode = false
@@ -426,15 +741,18 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.StatusMsg = "Git Filter: OFF"
 				}
 
-			// 按 'g' 切换 Git 模式
-			case "g":
-				m.GitMode = !m.GitMode
-				m.Cursor = 0 // 列表变了，重置光标
-				m.ScrollOffset = 0
-				if m.GitMode {
-					m.StatusMsg = "Git Filter: ON (Showing changed files)"
-				} else {
-					m.StatusMsg = "Git Filter: OFF"
+			// 按 'b' 输入对比的 ref (分支/提交)，留空恢复为工作区状态
+			case key.Matches(msg, m.Keys.DiffRef):
+				return m.openRefInput()
+
+			// 按 'f' 切换 Git 模式
+			case key.Matches(msg, m.Keys.GitFilter):
+				return m.toggleGitMode(), nil
+
+			// 其余按键交给自定义命令 (启动时已检查不会与内置按键冲突)
+			default:
+				if c, ok := m.customCommand(msg.String()); ok {
+					return m.runCommand(c)
 				}
 			}
 		}
@@ -443,6 +761,31 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// moveCursor 上下移动光标，并保持光标在视口内
+func (m *MainModel) moveCursor(delta int) {
+	// 限制光标不能超过文件树的总行数
+	totalNodes := m.countVisibleNodes(m.RootNode.Children)
+	cursor := m.Cursor + delta
+	if cursor > totalNodes-1 {
+		cursor = totalNodes - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	if cursor == m.Cursor {
+		return
+	}
+
+	m.Cursor = cursor
+	m.StatusMsg = "" // 移动光标时清除提示消息
+	vpHeight := m.viewportHeight()
+	if m.Cursor < m.ScrollOffset {
+		m.ScrollOffset = m.Cursor
+	} else if m.Cursor >= m.ScrollOffset+vpHeight {
+		m.ScrollOffset = m.Cursor - vpHeight + 1
+	}
+}
+
 // 触发防抖保存：更新 Tag，并返回一个延时指令
 func (m *MainModel) triggerDebouncedSave() tea.Cmd {
 	m.SaveTag++ // 版本号 +1
@@ -456,10 +799,19 @@ func (m *MainModel) triggerDebouncedSave() tea.Cmd {
 	})
 }
 
-// saveStateImmediate 立即保存当前状态到 .gentr.json (原 saveState)
-func (m MainModel) saveStateImmediate() {
-	cwd, _ := os.Getwd()
-	_ = core.SaveConfig(cwd, m.RootNode)
+// saveStateImmediate 立即保存当前状态到扫描根目录下的 .gentr.json (原 saveState)
+// 保存失败时在状态栏显示错误
+func (m *MainModel) saveStateImmediate() {
+	m.SaveErr = core.SaveConfig(m.RootPath, m.RootNode, m.Config)
+	if m.SaveErr != nil {
+		m.StatusMsg = "Error saving " + core.ConfigFileName + ": " + m.SaveErr.Error()
+	}
+	// 历史记录只是辅助功能，保存失败时仅提示
+	if m.History != nil {
+		if err := m.History.Save(); err != nil && m.SaveErr == nil {
+			m.StatusMsg = "Error saving undo history: " + err.Error()
+		}
+	}
 }
 
 // shouldShow 判断节点是否应该在当前过滤器(Search && Git)下显示
@@ -468,52 +820,31 @@ func (m MainModel) shouldShow(node *model.Node) bool {
 	searchTerm := m.SearchInput.Value()
 	matchesSearch := true
 	if searchTerm != "" {
-		matchesSearch = m.doesNodeMatch(node, strings.ToLower(searchTerm))
+		// 自身或子孙满足查询条件 (查询有语法错误时不过滤)
+		if results := m.searchResults(); results.err == nil {
+			matchesSearch = results.visible[node]
+		}
 	}
 
 	// 2. Git 状态检查
 	matchesGit := true
 	if m.GitMode {
-		matchesGit = m.doesNodeMatchGit(node)
+		matchesGit = node.HasGitChanges()
 	}
 
-	// 必须同时满足（交集）
-	return matchesSearch && matchesGit
-}
-
-// doesNodeMatch 递归搜索匹配检查
-func (m MainModel) doesNodeMatch(node *model.Node, term string) bool {
-	// 1. 检查自己
-	if strings.Contains(strings.ToLower(node.Name), term) {
-		return true
-	}
-	// 2. 检查子节点
-	// 判断“显不显示”只要有一个子节点匹配就行
-	if node.IsDir {
-		for _, child := range node.Children {
-			if m.doesNodeMatch(child, term) {
-				return true
-			}
-		}
+	// 3. 内容搜索检查
+	matchesGrep := true
+	if m.Grep != nil {
+		matchesGrep = m.Grep.counts[node] > 0
 	}
-	return false
+
+	// 必须同时满足（交集）
+	return matchesSearch && matchesGit && matchesGrep
 }
 
-// doesNodeMatchGit 递归 Git 匹配检查
-func (m MainModel) doesNodeMatchGit(node *model.Node) bool {
-	// 如果自己有状态 (M or A)，匹配
-	if node.GitStatus != "" {
-		return true
-	}
-	// 如果是文件夹，检查子节点有没有变的
-	if node.IsDir {
-		for _, child := range node.Children {
-			if m.doesNodeMatchGit(child) {
-				return true
-			}
-		}
-	}
-	return false
+// filtering 判断是否有过滤条件生效 (搜索、Git、内容搜索)，此时所有文件夹强制展开
+func (m MainModel) filtering() bool {
+	return m.SearchInput.Value() != "" || m.GitMode || m.Grep != nil
 }
 
 // View 渲染终端上的界面
@@ -540,19 +871,28 @@ func (m MainModel) View() string {
 	}
 
 	// 2. 警告条逻辑
-	if m.LimitWarning {
-		msg := "[!] Safety Limit Reached: Only showing first 5000 files / 10 levels deep."
-		topContent += warningStyle.Width(m.Width).Render(msg) + "\n"
+	// 说明触发了哪个限制以及截断的位置，超出宽度时截断 (警告条只占一行)
+	if m.Limits.Reached() {
+		msg := fmt.Sprintf("[!] Safety Limit Reached: %s | --max-files / --max-depth", m.Limits)
+		topContent += warningStyle.Width(m.Width).Render(truncateWidth(msg, m.Width-2)) + "\n"
 	}
 
-	// 3. 标题
+	// 3. 标题 (对比模式下显示对比的 ref)
 	header := fmt.Sprintf("Project: %s\n", m.RootNode.Name)
+	if m.DiffRef != "" {
+		header = fmt.Sprintf("Project: %s (diff: %s)\n", m.RootNode.Name, m.DiffRef)
+	}
 	topContent += header
 
 	// 递归渲染文件树
 	index := 0
 	// forceHidden 参数，初始为 false
-	fullTreeView := m.renderChildren(m.RootNode.Children, "", &index, false)
+	// 分栏预览时文件树只占左侧，按左侧宽度截断
+	treeModel := m
+	if m.previewVisible() {
+		treeModel.Width = m.treeWidth()
+	}
+	fullTreeView := treeModel.renderChildren(m.RootNode.Children, "", &index, false)
 
 	// 处理滚动逻辑
 	treeLines := strings.Split(fullTreeView, "\n")
@@ -563,6 +903,39 @@ func (m MainModel) View() string {
 
 	vpHeight := m.viewportHeight()
 	start := m.ScrollOffset
+
+	// 搜索结果列表替代文件树，滚动跟随列表光标
+	if m.SearchMode && m.RankedMode {
+		treeLines = m.renderRanked()
+		start = 0
+		if m.RankCursor+1 > vpHeight {
+			start = m.RankCursor + 1 - vpHeight
+		}
+	}
+
+	// 帮助面板替代文件树
+	if m.HelpMode {
+		treeLines = m.renderHelp()
+		start = m.HelpScroll
+	}
+
+	// 命令面板替代文件树，滚动跟随面板光标 (第一行是标题)
+	if m.PaletteMode {
+		treeLines = m.renderPalette()
+		start = 0
+		if m.PaletteCursor+2 > vpHeight {
+			start = m.PaletteCursor + 2 - vpHeight
+		}
+	}
+
+	// 失效注释面板替代文件树，滚动跟随面板光标 (第一行是标题)
+	if m.OrphanMode || m.RemapMode {
+		treeLines = m.renderOrphans()
+		start = 0
+		if m.OrphanCursor+2 > vpHeight {
+			start = m.OrphanCursor + 2 - vpHeight
+		}
+	}
 	end := start + vpHeight
 
 	// 边界检查
@@ -578,6 +951,17 @@ func (m MainModel) View() string {
 
 	visibleLines := treeLines[start:end]
 	treeView := strings.Join(visibleLines, "\n")
+	if m.previewVisible() {
+		treeView = m.joinPreview(visibleLines, vpHeight)
+	}
+
+	// 内容搜索时在树的下方固定显示匹配行预览
+	if m.Grep != nil {
+		for i := len(visibleLines); i < vpHeight; i++ {
+			treeView += "\n"
+		}
+		treeView += "\n" + m.renderGrepPreview()
+	}
 
 	// 底部区域逻辑：根据模式切换显示内容
 	bottomBar := ""
@@ -587,7 +971,58 @@ func (m MainModel) View() string {
 		bottomBar = fmt.Sprintf("\nAdding comment for selected file:\n%s\n(Enter to save, Esc to cancel)", m.TextInput.View())
 	} else if m.SearchMode {
 		// 2. 如果在搜索模式，显示搜索框
-		bottomBar = fmt.Sprintf("\n%s\n(Enter to view, Esc to cancel)", m.SearchInput.View())
+		hint := "(Enter to view, Tab for ranked list, Esc to cancel)"
+		if err := m.searchResults().err; err != nil {
+			hint = warningStyle.Render("Invalid query: " + err.Error())
+		} else if m.RankedMode {
+			hint = fmt.Sprintf("(%d matches | ↑/↓ select, Enter to jump, Tab for tree, Esc to cancel)", len(m.searchResults().ranked))
+		}
+		if partial := partialHint(m.searchResults().unloaded); partial != "" && m.SearchInput.Value() != "" {
+			hint += "  " + warningStyle.Render(partial)
+		}
+		bottomBar = fmt.Sprintf("\n%s\n%s", m.SearchInput.View(), hint)
+	} else if m.RefMode {
+		// 如果在对比 ref 输入模式，显示 ref 输入框
+		bottomBar = fmt.Sprintf("\nCompare working tree against git ref:\n%s\n(Enter to apply, Esc to cancel)", m.RefInput.View())
+	} else if m.GrepMode {
+		// 如果在内容搜索模式，显示输入框
+		bottomBar = fmt.Sprintf("\nSearch file contents:\n%s\n(Enter to search, empty to clear, Esc to cancel)", m.GrepInput.View())
+	} else if m.HelpMode {
+		// 帮助面板的提示
+		bottomBar = statusBarStyle.Width(m.Width).Render("Key bindings") +
+			"\n[↑/↓] Scroll  [PgUp/PgDn] Page  [Esc/?] Close"
+	} else if m.PaletteMode {
+		// 命令面板输入框
+		bottomBar = fmt.Sprintf("\nRun a command:\n%s\n%s", m.PaletteInput.View(), m.paletteHint())
+	} else if m.GotoMode {
+		// 跳转路径输入框
+		bottomBar = fmt.Sprintf("\nGo to path (relative to project root, fuzzy matched if not found):\n%s\n(Enter to jump, Esc to cancel)", m.GotoInput.View())
+	} else if m.FileOp == fileOpDelete {
+		// 删除前确认
+		bottomBar = fmt.Sprintf("\n%s\n%s It will be moved to the gentr trash folder.\n[y] Delete  [n/Esc] Cancel",
+			warningStyle.Render("Confirm delete"), m.deletePrompt())
+	} else if m.confirmCommand != nil {
+		// 运行项目命令前确认
+		bottomBar = fmt.Sprintf("\n%s\n%s defines this command: %s\n[y] Run and remember  [n/Esc] Cancel",
+			warningStyle.Render("Run project command?"), core.ConfigFileName, truncateWidth(m.confirmCommand.Run, m.Width-40))
+	} else if m.FileOp != "" {
+		// 文件操作路径输入框
+		bottomBar = fmt.Sprintf("\n%s\n%s\n(Enter to apply, Esc to cancel)", m.fileOpPrompt(), m.FileInput.View())
+	} else if m.RemapMode {
+		// 如果在重新关联模式，显示路径输入框
+		bottomBar = fmt.Sprintf("\nRe-attach to path (relative to project root):\n%s\n(Enter to apply, Esc to cancel)", m.RemapInput.View())
+	} else if m.OrphanMode {
+		// 失效注释面板的状态栏 + 帮助
+		statusText := m.StatusMsg
+		if statusText == "" {
+			statusText = fmt.Sprintf("%d orphaned entries", len(m.orphans()))
+		}
+		bottomBar = statusBarStyle.Width(m.Width).Render(statusText) + "\n" + joinHints(m.Width, []string{
+			keyHint("Select", m.Keys.Up, m.Keys.Down),
+			"[Ent] Re-attach",
+			keyHint("Discard", m.Keys.Delete),
+			keyHint("Back", m.Keys.Clear, m.Keys.Orphans),
+		})
 	} else {
 		// 3. 如果在导航模式，显示状态栏 + 帮助
 		// 状态栏逻辑：优先显示 StatusMsg
@@ -605,6 +1040,11 @@ func (m MainModel) View() string {
 			}
 		}
 
+		// 有多选时在状态栏前显示选中数量
+		if n := len(m.Selected); n > 0 {
+			statusText = fmt.Sprintf("[%d selected] %s", n, statusText)
+		}
+
 		// 只有当宽度足够时才进行截断操作，防止 Panic
 		if m.Width > 5 && len(statusText) > m.Width-2 {
 			statusText = statusText[:m.Width-5] + "..."
@@ -617,20 +1057,41 @@ func (m MainModel) View() string {
 		}
 		statusBar := currentStatusBarStyle.Width(m.Width).Render(statusText)
 
-		// 提示文案
-		filterHint := ""
-		if m.SearchInput.Value() != "" {
-			filterHint += " [Esc] Clear Search"
+		// 帮助文案，由当前的按键绑定生成，放不下的提示从行尾省略
+		keys := m.Keys
+		line1 := []string{
+			keyHint("Fold", keys.Fold),
+			keyHint("Hide", keys.Hide),
+			keyHint("Comment", keys.Comment),
+			keyHint("Select", keys.Select),
+			keyHint("Search", keys.Search),
+			keyHint("Grep", keys.Grep),
+		}
+		if len(m.Selected) > 0 {
+			line1 = append(line1, keyHint("Clear Selection", keys.Clear))
+		} else if m.SearchInput.Value() != "" || m.Grep != nil {
+			line1 = append(line1, keyHint("Clear Search", keys.Clear))
 		}
 		// Git 模式提示
 		if m.GitMode {
-			filterHint += " [g] All Files"
+			line1 = append(line1, keyHint("All Files", keys.GitFilter))
 		} else {
-			filterHint += " [g] Git Changes"
+			line1 = append(line1, keyHint("Git Changes", keys.GitFilter))
 		}
-
-		// 帮助文案
-		help := fmt.Sprintf("\n[Spc] Toggle  [Ent] Hide/Show  [i] Comment  [/] Search  %s\n[c] Copy  [s] Save Txt  [p] Save SVG  [q] Quit", filterHint)
+		line2 := []string{
+			keyHint("Help", keys.Help),
+			keyHint("Commands", keys.Palette),
+			keyHint("Preview", keys.Preview),
+			keyHint("Edit", keys.Edit),
+			keyHint("File Ops", keys.Create, keys.Rename, keys.Move, keys.Copy, keys.Delete),
+			keyHint("Copy", keys.CopyTree),
+			keyHint("Save", keys.SaveText, keys.SaveSVG, keys.SaveJSON),
+			keyHint("Quit", keys.Quit),
+		}
+		if len(m.orphans()) > 0 {
+			line2 = append(line2, keyHint("Orphans", keys.Orphans))
+		}
+		help := "\n" + joinHints(m.Width, line1) + "\n" + joinHints(m.Width, line2)
 		bottomBar = statusBar + help
 	}
 
@@ -681,17 +1142,20 @@ func (m MainModel) renderChildren(children []*model.Node, prefix string, index *
 		// Git 颜色逻辑
 		// 优先级：光标 > 搜索匹配 > Git状态 > 普通
 		// 如果不在光标上，且没有被隐藏
+		gitStyle, hasGitStyle := gitStyleFor(child)
 		if *index != m.Cursor && !isNodeHidden {
-			if child.GitStatus == "M" {
-				style = gitModifiedStyle
-			} else if child.GitStatus == "A" {
-				style = gitAddedStyle
+			if hasGitStyle {
+				style = gitStyle
 			}
 
-			// 搜索高亮逻辑 (保留)
-			term := m.SearchInput.Value()
-			if term != "" && strings.Contains(strings.ToLower(child.Name), strings.ToLower(term)) {
-				style = searchMatchStyle
+		}
+
+		// 多选标记
+		marked := m.Selected[child]
+		if marked {
+			cursorIndicator = " •"
+			if !isNodeHidden {
+				style = markedStyle
 			}
 		}
 
@@ -702,6 +1166,9 @@ func (m MainModel) renderChildren(children []*model.Node, prefix string, index *
 			if isNodeHidden {
 				style = selectedHiddenStyle
 			}
+			if marked {
+				cursorIndicator = ">•"
+			}
 		}
 
 		// 文件夹指示处理
@@ -723,82 +1190,71 @@ func (m MainModel) renderChildren(children []*model.Node, prefix string, index *
 		// 预留 1 个字符防止边缘溢出
 		availableWidth := m.Width - prefixWidth - 1
 
-		displayName := child.Name
+		// 构造 Git 标记，重命名/复制时附带来源路径
+		row := rowParts{
+			name:      child.Name,
+			gitMark:   export.GitMark(child),
+			gitDetail: export.GitDetail(child),
+			grepBadge: m.grepBadge(child),    // 内容搜索时追加匹配数徽标 (3)
+			loadBadge: m.loadingBadge(child), // 懒加载时正在读取的文件夹追加加载指示
+		}
 
-		// 构造 Git 标记
-		gitMark := ""
-		if child.GitStatus == "M" {
-			gitMark = " [M]"
-		} else if child.GitStatus == "A" {
-			gitMark = " [+]"
+		// Git 模式下追加行数变化徽标 (+12 −3)
+		if m.GitMode {
+			row.lineBadge = export.LineBadge(child)
 		}
 
 		// 处理注释的显示逻辑
-		annotationStr := ""
 		if child.Annotation != "" {
-			annotationStr = fmt.Sprintf("  # %s", child.Annotation)
+			row.annotation = fmt.Sprintf("  # %s", child.Annotation)
 		}
 
-		// 拼接顺序：文件名 + Git标记 + 注释
-		totalContent := displayName + gitMark + annotationStr
+		// 按可用宽度截断，优先保证文件名
+		row = row.fit(availableWidth)
+		displayName := row.name
 
-		// 增加对极小宽度的判断，防止 availableWidth < 0 导致 crash
-		if availableWidth <= 1 {
-			displayName = "" // 空间太小，直接不显示
-			annotationStr = ""
-			gitMark = "" // [新增]
-		} else {
-			// 计算总内容宽度 (名字 + Git标记 + 注释)
-			totalWidth := lipgloss.Width(totalContent)
-
-			// 如果总宽度超过可用空间，需要截断
-			if totalWidth > availableWidth {
-				// 这里的截断策略：优先保证文件名，然后是 Git 标记，最后是注释
-				// 为了简化 MVP，我们直接截断 annotationStr
-				// 重新计算除注释外的基础宽度
-				baseLen := lipgloss.Width(displayName + gitMark)
-				if baseLen >= availableWidth {
-					// 空间极其紧张，只显示名字
-					annotationStr = ""
-					gitMark = ""
-					runesName := []rune(displayName)
-					if availableWidth-1 > 0 {
-						displayName = string(runesName[:availableWidth-1]) + "…"
-					}
-				} else {
-					// 截断注释
-					remain := availableWidth - baseLen
-					runesAnno := []rune(annotationStr)
-					if remain > 1 && remain < len(runesAnno) {
-						annotationStr = string(runesAnno[:remain-1]) + "…"
-					} else {
-						annotationStr = ""
-					}
-				}
-			}
+		// 搜索命中字符的样式：光标行加下划线，隐藏行不高亮
+		nameMatchStyle := searchMatchStyle
+		if *index == m.Cursor {
+			nameMatchStyle = style.Underline(true)
+		} else if isNodeHidden {
+			nameMatchStyle = style
 		}
 
 		// 渲染 Git 标记的样式
 		gitMarkStyle := normalStyle
 		if !isNodeHidden {
-			if child.GitStatus == "M" {
-				gitMarkStyle = gitModifiedStyle
-			} else if child.GitStatus == "A" {
-				gitMarkStyle = gitAddedStyle
+			if hasGitStyle {
+				gitMarkStyle = gitStyle
 			}
 		} else {
 			gitMarkStyle = hiddenStyle
 		}
 
-		// 拼接字符串：光标指示器 + 缩进 + 连接符 + 文件名 + [Git标记] + [注释]
-		line := fmt.Sprintf("%s%s%s%s%s%s%s",
+		// 渲染行数徽标：新增为绿色，删除为红色
+		badgeView := ""
+		if row.lineBadge != "" {
+			added, removed := export.LineCounts(child)
+			if added != "" {
+				badgeView += " " + linesAddedStyle.Render(added)
+			}
+			if removed != "" {
+				badgeView += " " + linesRemovedStyle.Render(removed)
+			}
+		}
+
+		// 拼接字符串：光标指示器 + 缩进 + 连接符 + 文件名 + [Git标记] + [行数徽标] + [匹配数] + [加载指示] + [注释]
+		line := fmt.Sprintf("%s%s%s%s%s%s%s%s%s%s",
 			cursorIndicator,
 			dimmedStyle.Render(prefix),
 			dimmedStyle.Render(connector),
 			icon,
-			style.Render(displayName),
-			gitMarkStyle.Render(gitMark), // 渲染 Git 标记
-			annotationStyle.Render(annotationStr),
+			m.highlightName(child, displayName, style, nameMatchStyle), // 只高亮模糊匹配命中的字符
+			gitMarkStyle.Render(row.gitMark+row.gitDetail),             // 渲染 Git 标记
+			badgeView,
+			grepCountStyle.Render(row.grepBadge),
+			dimmedStyle.Render(row.loadBadge),
+			annotationStyle.Render(row.annotation),
 		)
 		sb.WriteString(line + "\n")
 
@@ -808,7 +1264,7 @@ func (m MainModel) renderChildren(children []*model.Node, prefix string, index *
 		// 如果是文件夹且有子节点，递归渲染其子节点
 		// 强制展开逻辑：搜索 或 Git模式下都强制展开
 		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
+		if m.filtering() {
 			shouldExpand = true // 强制展开
 		}
 
@@ -827,6 +1283,82 @@ func (m MainModel) renderChildren(children []*model.Node, prefix string, index *
 	return sb.String()
 }
 
+// rowParts 是树中一行在连接符与图标之后的内容，按显示顺序排列
+type rowParts struct {
+	name       string
+	gitMark    string // Git 状态标记，例如 " [M]"
+	gitDetail  string // 重命名/复制的来源路径，例如 " ← old/path.go"
+	lineBadge  string
+	grepBadge  string
+	loadBadge  string
+	annotation string
+}
+
+// fit 把一行内容压缩到 width 个显示宽度之内
+// 文件名最优先，只有文件名本身放不下时才截断它；
+// 其余部分按 Git 标记、徽标、来源路径、注释的顺序占用剩下的空间，放不下的徽标被丢弃，来源路径与注释被截断
+func (p rowParts) fit(width int) rowParts {
+	if width <= 1 {
+		return rowParts{}
+	}
+	if lipgloss.Width(p.name) > width {
+		return rowParts{name: truncateWidth(p.name, width)}
+	}
+
+	remain := width - lipgloss.Width(p.name)
+	// take 在剩余空间足够时保留整段内容，否则丢弃
+	take := func(s string) string {
+		if w := lipgloss.Width(s); w <= remain {
+			remain -= w
+			return s
+		}
+		return ""
+	}
+	// shorten 在剩余空间足够时保留整段内容，否则截断到剩余空间 (至少保留 min 个宽度才显示)
+	shorten := func(s string, min int) string {
+		if s == "" || remain < min {
+			return ""
+		}
+		s = truncateWidth(s, remain)
+		remain -= lipgloss.Width(s)
+		return s
+	}
+
+	fitted := rowParts{name: p.name}
+	fitted.gitMark = take(p.gitMark)
+	fitted.lineBadge = take(p.lineBadge)
+	fitted.grepBadge = take(p.grepBadge)
+	fitted.loadBadge = take(p.loadBadge)
+	if fitted.gitMark != "" {
+		fitted.gitDetail = shorten(p.gitDetail, lipgloss.Width(" ← ")+2)
+	}
+	fitted.annotation = shorten(p.annotation, lipgloss.Width("  # ")+2)
+	return fitted
+}
+
+// gitStyleFor 根据节点的 Git 样式类别返回对应的终端样式
+func gitStyleFor(node *model.Node) (lipgloss.Style, bool) {
+	switch export.GitClass(node) {
+	case export.GitClassModified:
+		return gitModifiedStyle, true
+	case export.GitClassAdded:
+		return gitAddedStyle, true
+	case export.GitClassDeleted:
+		return gitDeletedStyle, true
+	case export.GitClassRenamed:
+		return gitRenamedStyle, true
+	case export.GitClassType:
+		return gitTypeStyle, true
+	case export.GitClassConflict:
+		return gitConflictStyle, true
+	case export.GitClassStaged:
+		return gitStagedStyle, true
+	case export.GitClassIgnored:
+		return gitIgnoredStyle, true
+	}
+	return normalStyle, false
+}
+
 // countNodes 计算当前可见节点的数量，用于防止光标越界
 func (m MainModel) countVisibleNodes(children []*model.Node) int {
 	count := 0
@@ -840,7 +1372,7 @@ func (m MainModel) countVisibleNodes(children []*model.Node) int {
 
 		// 强制展开逻辑
 		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
+		if m.filtering() {
 			shouldExpand = true
 		}
 
@@ -870,7 +1402,7 @@ func (m MainModel) toggleNode(children []*model.Node, index *int) bool {
 
 		// 强制展开逻辑
 		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
+		if m.filtering() {
 			shouldExpand = true
 		}
 
@@ -899,7 +1431,7 @@ func (m MainModel) toggleHidden(children []*model.Node, index *int) bool {
 
 		// 强制展开逻辑
 		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
+		if m.filtering() {
 			shouldExpand = true
 		}
 
@@ -927,7 +1459,7 @@ func (m MainModel) getNodeAtCursor(children []*model.Node, index *int) *model.No
 
 		// 强制展开逻辑
 		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
+		if m.filtering() {
 			shouldExpand = true
 		}
 
@@ -941,257 +1473,115 @@ func (m MainModel) getNodeAtCursor(children []*model.Node, index *int) *model.No
 	return nil
 }
 
-// generateTreeOutput 生成纯文本树，复制到剪贴板用
-func (m MainModel) generateTreeOutput() string {
-	var sb strings.Builder
-	// 根目录不带前缀
-	sb.WriteString(fmt.Sprintf("%s\n", m.RootNode.Name))
-
-	// 递归生成子节点，过滤掉 Hidden 的
-	// 不需要 index 指针也不需要 cursor 逻辑，只需要纯粹的遍历
-	sb.WriteString(m.generateChildrenText(m.RootNode.Children, ""))
-	return sb.String()
-}
-
-// 递归生成纯文本内容
-func (m MainModel) generateChildrenText(children []*model.Node, prefix string) string {
-	var sb strings.Builder
-
-	// 1. 先过滤：找出所有没被隐藏的子节点
-	// 增加 shouldShow 过滤，确保导出的内容和看到的搜索结果一致
-	var visibleChildren []*model.Node
-	for _, child := range children {
-		if !child.Hidden && m.shouldShow(child) {
-			visibleChildren = append(visibleChildren, child)
-		}
+// exportOptions 根据当前的过滤状态 (Search && Git) 构造导出选项
+func (m MainModel) exportOptions() export.Options {
+	return export.Options{
+		GitMode: m.GitMode,
+		Expand:  m.filtering(),
+		Filter:  m.shouldShow,
 	}
-
-	// 2. 遍历可见节点
-	for i, child := range visibleChildren {
-		isLast := i == len(visibleChildren)-1
-
-		connector := "├── "
-		if isLast {
-			connector = "└── "
-		}
-
-		// 根据 GitMode 决定是否追加 Git 标记
-		gitSuffix := ""
-		if m.GitMode {
-			if child.GitStatus == "M" {
-				gitSuffix = " [M]"
-			} else if child.GitStatus == "A" {
-				gitSuffix = " [+]"
-			}
-		}
-
-		// 输出行：前缀 + 连接线 + 文件名 + [Git标记] + [注释]
-		line := fmt.Sprintf("%s%s%s%s", prefix, connector, child.Name, gitSuffix)
-
-		if child.Annotation != "" {
-			// 导出时的注释格式，用空格对齐
-			line += fmt.Sprintf("  # %s", child.Annotation)
-		}
-		sb.WriteString(line + "\n")
-
-		// 递归处理子文件夹
-		// 强制展开逻辑
-		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
-			shouldExpand = true
-		}
-
-		if child.IsDir && len(child.Children) > 0 && shouldExpand {
-			childPrefix := prefix + "│   "
-			if isLast {
-				childPrefix = prefix + "    "
-			}
-			sb.WriteString(m.generateChildrenText(child.Children, childPrefix))
-		}
-	}
-	return sb.String()
 }
 
-// 核心重构：SVG 渲染引擎
-
-// saveThemeSVG 负责生成带主题的 SVG
-func (m MainModel) saveThemeSVG(theme Theme, filename string) error {
-	lineHeight := 24 // 增加行高，更宽松
-
-	// 1. 生成内容 (XML 格式)
-	content, lineCount, maxCharWidth := m.generateSVGContent(m.RootNode, theme)
+// quit 立即保存后退出
+func (m MainModel) quit() (tea.Model, tea.Cmd) {
+	m.Quitting = true
+	// 退出前强制立即保存一次，防止防抖还没触发就退出了
+	m.saveStateImmediate()
+	// 直接 Quit，屏幕恢复由 WithAltScreen 接管
+	return m, tea.Quit
+}
 
-	// 2. 计算画布
-	width := (maxCharWidth * 10) + 60 // 10px per char + padding
-	if width < 600 {
-		width = 600
-	} // 最小宽度
-	height := (lineCount * lineHeight) + 60
+// openSearch 进入搜索模式
+func (m MainModel) openSearch() (tea.Model, tea.Cmd) {
+	m.SearchMode = true
+	m.SearchInput.Focus()
+	return m, textinput.Blink
+}
 
-	var sb strings.Builder
-	// Header
-	sb.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, width, height))
-	// Background
-	sb.WriteString(fmt.Sprintf(`<rect width="100%%" height="100%%" fill="%s" />`, theme.BgColor))
-
-	// 字体栈：显式声明中文字体
-	fontFamily := "'Consolas', 'Monaco', 'Microsoft YaHei', 'PingFang SC', 'WenQuanYi Micro Hei', monospace"
-
-	// Style
-	sb.WriteString(fmt.Sprintf(`<style>
-		text { 
-			font-family: %s; 
-			font-size: 14px; 
-			white-space: pre; 
-		}
-		.tree { fill: %s; }
-		.text { fill: %s; }
-		.folder { fill: %s; font-weight: bold; }
-		.comment { fill: %s; font-style: italic; }
-		.git-mod { fill: %s; font-weight: bold; }
-		.git-add { fill: %s; font-weight: bold; }
-	</style>`,
-		fontFamily,
-		theme.TreeColor, theme.TextColor, theme.FolderColor, theme.CommentColor, theme.GitModColor, theme.GitAddColor))
-
-	// Padding Container (Translate)
-	sb.WriteString(`<g transform="translate(30, 40)">`) // 左上角留白
-	sb.WriteString(content)
-	sb.WriteString(`</g></svg>`)
-
-	return os.WriteFile(filename, []byte(sb.String()), 0644)
+// openRefInput 输入对比的 ref (分支/提交)，留空恢复为工作区状态
+func (m MainModel) openRefInput() (tea.Model, tea.Cmd) {
+	m.RefMode = true
+	m.RefInput.SetValue(m.DiffRef)
+	m.RefInput.CursorEnd()
+	m.RefInput.Focus()
+	return m, textinput.Blink
 }
 
-// generateSVGContent 递归生成 SVG 内容
-func (m MainModel) generateSVGContent(root *model.Node, theme Theme) (string, int, int) {
-	var sb strings.Builder
-	lineIndex := 0
-	maxLen := 0
+// copyTree 把文件树复制到剪贴板
+func (m MainModel) copyTree() (tea.Model, tea.Cmd) {
+	// 使用 markdown 代码块包裹，方便直接粘贴到文档
+	finalText := export.Markdown(m.RootNode, m.exportOptions())
 
-	// 根节点，先递增行号
-	lineIndex++
-	sb.WriteString(fmt.Sprintf(`<text x="0" y="%d" class="folder">%s</text>`, lineIndex*24-24, escapeXML(root.Name)))
-	if len(root.Name) > maxLen {
-		maxLen = len(root.Name)
+	err := clipboard.WriteAll(finalText)
+	if err != nil {
+		m.StatusMsg = "Error copying to clipboard!"
+	} else {
+		m.StatusMsg = "Copied to clipboard!"
 	}
+	// 返回一个空的 Tick 强制触发 View 刷新以显示 StatusMsg
+	return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
+}
 
-	// 递归
-	childContent, _, childMax := m.writeSVGRecursive(root.Children, "", theme, &lineIndex)
-	sb.WriteString(childContent)
-
-	if childMax > maxLen {
-		maxLen = childMax
+// saveText 保存为文本文件
+func (m MainModel) saveText() (tea.Model, tea.Cmd) {
+	output := export.Text(m.RootNode, m.exportOptions())
+	filename, err := m.Settings.ExportPath(m.RootPath, "gentr_output.txt")
+	if err == nil {
+		err = os.WriteFile(filename, []byte(output), 0644)
 	}
-	return sb.String(), lineIndex, maxLen
+	if err != nil {
+		m.StatusMsg = "Error saving file: " + err.Error()
+	} else {
+		m.StatusMsg = fmt.Sprintf("Saved to %s", filename)
+	}
+	return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
 }
 
-// writeSVGRecursive 递归生成 XML 标签
-func (m MainModel) writeSVGRecursive(children []*model.Node, prefix string, theme Theme, lineIndex *int) (string, int, int) {
-	var sb strings.Builder
-	maxLen := 0
-	lineHeight := 24
-
-	var visibleChildren []*model.Node
-	for _, child := range children {
-		if !child.Hidden && m.shouldShow(child) {
-			visibleChildren = append(visibleChildren, child)
-		}
+// saveSVG 保存 SVG 图片：配置了主题时只保存该主题，否则保存两套
+func (m MainModel) saveSVG() (tea.Model, tea.Cmd) {
+	themes := []string{"dark", "light"}
+	if m.Settings.Theme != "" {
+		themes = []string{m.Settings.Theme}
 	}
 
-	for i, child := range visibleChildren {
-		*lineIndex = *lineIndex + 1 // 先递增行号
-		currentY := (*lineIndex - 1) * lineHeight
-
-		isLast := i == len(visibleChildren)-1
-		connector := "├── "
-		if isLast {
-			connector = "└── "
-		}
-
-		// 开始一行
-		sb.WriteString(fmt.Sprintf(`<text x="0" y="%d">`, currentY))
-
-		// 1. 树形线条
-		sb.WriteString(fmt.Sprintf(`<tspan class="tree">%s%s</tspan>`, escapeXML(prefix), connector))
-
-		// 2. 图标 (可选，为了美观这里统一用箭头或留空)
-		icon := ""
-		if child.IsDir {
-			icon = "▼ "
-		}
-		if icon != "" {
-			sb.WriteString(fmt.Sprintf(`<tspan class="tree">%s</tspan>`, icon))
-		}
-
-		// 3. 文件名 (根据 Git 状态变色)
-		nameClass := "text"
-		if child.IsDir {
-			nameClass = "folder"
-		}
-		if m.GitMode {
-			if child.GitStatus == "M" {
-				nameClass = "git-mod"
-			}
-			if child.GitStatus == "A" {
-				nameClass = "git-add"
-			}
-		}
-		sb.WriteString(fmt.Sprintf(`<tspan class="%s">%s</tspan>`, nameClass, escapeXML(child.Name)))
-
-		// 4. Git 标记 (仅在 Git 模式下)
-		if m.GitMode {
-			mark := ""
-			markClass := ""
-			if child.GitStatus == "M" {
-				mark = " [M]"
-				markClass = "git-mod"
-			} else if child.GitStatus == "A" {
-				mark = " [+]"
-				markClass = "git-add"
-			}
-			if mark != "" {
-				sb.WriteString(fmt.Sprintf(`<tspan class="%s">%s</tspan>`, markClass, mark))
-			}
-		}
-
-		// 5. 注释
-		if child.Annotation != "" {
-			sb.WriteString(fmt.Sprintf(`<tspan class="comment">  # %s</tspan>`, escapeXML(child.Annotation)))
-		}
-
-		sb.WriteString(`</text>`)
-
-		// 计算粗略宽度
-		rowLen := len(prefix) + 4 + len(child.Name) + len(child.Annotation) + 5
-		if rowLen > maxLen {
-			maxLen = rowLen
-		}
-
-		// 递归子节点
-		shouldExpand := !child.Collapsed
-		if m.SearchInput.Value() != "" || m.GitMode {
-			shouldExpand = true
+	var saved []string
+	for _, name := range themes {
+		theme, _ := export.ThemeByName(name)
+		filename, err := m.saveThemeSVG(theme, "gentr_"+name+".svg")
+		if err != nil {
+			m.StatusMsg = "Error saving SVG!"
+			return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
 		}
+		saved = append(saved, filename)
+	}
+	m.StatusMsg = "Saved " + strings.Join(saved, " & ")
+	return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
+}
 
-		if child.IsDir && len(child.Children) > 0 && shouldExpand {
-			childPrefix := prefix + "│   "
-			if isLast {
-				childPrefix = prefix + "    "
-			}
-			cContent, _, cMax := m.writeSVGRecursive(child.Children, childPrefix, theme, lineIndex)
-			sb.WriteString(cContent)
-			if cMax > maxLen {
-				maxLen = cMax
-			}
-		}
+// saveJSON 导出 JSON (与文本导出使用相同的过滤规则)
+func (m MainModel) saveJSON() (tea.Model, tea.Cmd) {
+	filename, err := m.Settings.ExportPath(m.RootPath, "gentr_output.json")
+	var data []byte
+	if err == nil {
+		data, err = export.JSON(m.RootNode, m.exportOptions(), false)
 	}
-	return sb.String(), 0, maxLen
+	if err == nil {
+		err = os.WriteFile(filename, data, 0644)
+	}
+	if err != nil {
+		m.StatusMsg = "Error saving JSON: " + err.Error()
+	} else {
+		m.StatusMsg = fmt.Sprintf("Saved to %s", filename)
+	}
+	return m, tea.Tick(time.Millisecond, func(t time.Time) tea.Msg { return nil })
 }
 
-// escapeXML 转义 XML 特殊字符
-func escapeXML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	return s
+// saveThemeSVG 负责生成带主题的 SVG 并写入导出目录，返回写入的路径
+func (m MainModel) saveThemeSVG(theme export.Theme, filename string) (string, error) {
+	path, err := m.Settings.ExportPath(m.RootPath, filename)
+	if err != nil {
+		return "", err
+	}
+	content := export.SVG(m.RootNode, theme, m.exportOptions())
+	return path, os.WriteFile(path, []byte(content), 0644)
 }
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// gitRefreshMsg 携带重新加载的 Git 状态
+type gitRefreshMsg struct {
+	ref     string
+	changes *core.GitChanges
+	err     error
+	status  string // 非空时替代默认的状态栏提示 (后台刷新时使用)
+}
+
+// updateRefMode 处理对比 ref 输入框的按键
+func (m MainModel) updateRefMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			m.RefMode = false
+			ref := strings.TrimSpace(m.RefInput.Value())
+			if ref == "" {
+				m.StatusMsg = "Loading working tree status..."
+			} else {
+				m.StatusMsg = "Comparing against " + ref + "..."
+			}
+			return m, m.loadGitCmd(ref)
+
+		case "esc":
+			m.RefMode = false
+			m.StatusMsg = "Cancelled."
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.RefInput, cmd = m.RefInput.Update(msg)
+	return m, cmd
+}
+
+// loadGitCmd 在后台重新加载 Git 状态，ref 为空时读取工作区状态
+func (m MainModel) loadGitCmd(ref string) tea.Cmd {
+	rootPath := m.RootPath
+	opts := m.WalkOpts
+	opts.DiffRef = ref
+
+	return func() tea.Msg {
+		changes, err := core.LoadGitChanges(rootPath, opts)
+		return gitRefreshMsg{ref: ref, changes: changes, err: err}
+	}
+}
+
+// refreshGitCmd 在后台按当前的对比 ref 重新加载 Git 状态，完成后显示 status
+func (m MainModel) refreshGitCmd(status string) tea.Cmd {
+	load := m.loadGitCmd(m.DiffRef)
+	return func() tea.Msg {
+		msg := load().(gitRefreshMsg)
+		msg.status = status
+		return msg
+	}
+}
+
+// handleGitRefresh 把新的 Git 状态应用到树上，对比模式下自动打开 Git 过滤
+func (m MainModel) handleGitRefresh(msg gitRefreshMsg) MainModel {
+	if msg.err != nil {
+		m.StatusMsg = "Error: " + msg.err.Error()
+		return m
+	}
+
+	idx := 0
+	current := m.getNodeAtCursor(m.RootNode.Children, &idx)
+
+	core.ApplyGitChanges(m.RootNode, msg.changes)
+	m.resort() // 幽灵节点按文件名插入，行数变化也可能影响排序
+	m.invalidateSearch()
+	m.DiffRef = msg.ref
+	m.WalkOpts.DiffRef = msg.ref
+
+	// 树的结构 (幽灵节点) 可能变了，重置光标
+	m.Cursor = 0
+	m.ScrollOffset = 0
+
+	if msg.status != "" {
+		// 后台刷新：尽量保持光标停在原来的节点上
+		if current != nil {
+			m.jumpTo(current)
+		}
+		m.StatusMsg = msg.status
+	} else if msg.ref != "" {
+		m.GitMode = true
+		m.StatusMsg = "Git Filter: ON (Changes vs " + msg.ref + ")"
+		if hint := partialHint(m.unloadedCount()); hint != "" {
+			m.StatusMsg += " | " + hint
+		}
+	} else {
+		m.StatusMsg = "Git status reloaded (working tree)"
+	}
+	return m
+}
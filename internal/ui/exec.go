@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// execDoneMsg 在外部程序 (编辑器、shell、自定义命令) 退出、TUI 恢复后发送
+type execDoneMsg struct {
+	title string
+	err   error
+}
+
+// execProcess 挂起 TUI 运行外部程序，结束后恢复并刷新 Git 状态
+func (m MainModel) execProcess(title string, cmd *exec.Cmd) (tea.Model, tea.Cmd) {
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return execDoneMsg{title: title, err: err}
+	})
+}
+
+// openInEditor 用 $VISUAL / $EDITOR 打开光标所在的节点
+func (m MainModel) openInEditor() (tea.Model, tea.Cmd) {
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	if node == nil {
+		return m, nil
+	}
+	if node.Ghost {
+		m.StatusMsg = node.Name + " no longer exists on disk"
+		return m, nil
+	}
+	return m.execProcess("editor", core.EditorCommand(node.Path))
+}
+
+// openShell 在光标所在的文件夹 (文件则为其所在文件夹) 中打开交互式 shell
+func (m MainModel) openShell() (tea.Model, tea.Cmd) {
+	dir := m.RootPath
+	idx := 0
+	if node := m.getNodeAtCursor(m.RootNode.Children, &idx); node != nil && !node.Ghost {
+		dir = node.Path
+		if !node.IsDir {
+			dir = filepath.Dir(node.Path)
+		}
+	}
+	return m.execProcess("shell", core.InteractiveShell(dir))
+}
+
+// customCommand 查找绑定到按键的自定义命令
+func (m MainModel) customCommand(key string) (core.Command, bool) {
+	for _, c := range m.Settings.Commands {
+		if c.Key == key && c.Run != "" {
+			return c, true
+		}
+	}
+	return core.Command{}, false
+}
+
+// runCommand 以光标所在的节点替换占位符后执行自定义命令
+// .gentr.json 中的命令随项目分发，第一次运行 (或命令被修改后) 需要用户确认
+func (m MainModel) runCommand(c core.Command) (tea.Model, tea.Cmd) {
+	if c.Source == core.SourceProject && !core.IsCommandTrusted(m.RootPath, c.Run) {
+		m.confirmCommand = &c
+		return m, nil
+	}
+	return m.execCommand(c)
+}
+
+// updateConfirmCommand 处理运行项目命令前的确认提示
+func (m MainModel) updateConfirmCommand(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	c := *m.confirmCommand
+	switch keyMsg.String() {
+	case "y", "Y":
+		m.confirmCommand = nil
+		if err := core.TrustCommand(m.RootPath, c.Run); err != nil {
+			// 无法记录时只允许这一次，下次仍然会询问
+			m.StatusMsg = "Error remembering command: " + err.Error()
+		}
+		return m.execCommand(c)
+	case "n", "N", "esc", "q":
+		m.confirmCommand = nil
+		m.StatusMsg = "Cancelled."
+	}
+	return m, nil
+}
+
+// execCommand 执行自定义命令
+func (m MainModel) execCommand(c core.Command) (tea.Model, tea.Cmd) {
+	path, isDir := m.RootPath, true
+	idx := 0
+	if node := m.getNodeAtCursor(m.RootNode.Children, &idx); node != nil {
+		path, isDir = node.Path, node.IsDir
+	}
+	script := core.ExpandCommand(c.Run, m.RootPath, path, isDir)
+	cmd := core.ShellCommand(script, c.Wait)
+	cmd.Dir = m.RootPath
+	return m.execProcess(c.Title(), cmd)
+}
+
+// handleExecDone 外部程序退出后显示结果，并在后台刷新 Git 状态 (文件可能被修改了)
+func (m MainModel) handleExecDone(msg execDoneMsg) (tea.Model, tea.Cmd) {
+	status := "Returned from " + msg.title
+	if msg.err != nil {
+		status = "Error running " + msg.title + ": " + msg.err.Error()
+	}
+	m.StatusMsg = status
+	return m, m.refreshGitCmd(status)
+}
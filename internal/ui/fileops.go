@@ -0,0 +1,264 @@
+package ui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 文件操作的种类 (MainModel.FileOp)
+const (
+	fileOpCreate = "create"
+	fileOpRename = "rename"
+	fileOpMove   = "move"
+	fileOpCopy   = "copy"
+	fileOpDelete = "delete" // 只需确认，不显示输入框
+)
+
+// openFileOp 为光标所在的节点打开文件操作的输入框 (删除则是确认提示)
+func (m MainModel) openFileOp(op string) (tea.Model, tea.Cmd) {
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+
+	// 新建时以光标所在的文件夹 (文件则为其所在文件夹) 为默认位置
+	if op == fileOpCreate {
+		value := ""
+		if node != nil {
+			dir := core.NodeRelPath(m.RootNode, node)
+			if !node.IsDir || node.Ghost {
+				dir = path.Dir(dir)
+			}
+			if dir != "." {
+				value = dir + "/"
+			}
+		}
+		m.FileOp = op
+		m.fileTarget = nil
+		m.FileInput.SetValue(value)
+		m.FileInput.CursorEnd()
+		m.FileInput.Focus()
+		return m, textinput.Blink
+	}
+
+	// 有多选时删除作用于所有选中的节点
+	if op == fileOpDelete && len(m.Selected) > 0 {
+		m.FileOp = op
+		m.fileTarget = nil
+		return m, nil
+	}
+
+	if node == nil {
+		return m, nil
+	}
+	if node.Ghost {
+		m.StatusMsg = node.Name + " no longer exists on disk"
+		return m, nil
+	}
+
+	m.FileOp = op
+	m.fileTarget = node
+	switch op {
+	case fileOpDelete:
+		return m, nil
+	case fileOpRename:
+		m.FileInput.SetValue(node.Name)
+	default:
+		m.FileInput.SetValue(core.NodeRelPath(m.RootNode, node))
+	}
+	m.FileInput.CursorEnd()
+	m.FileInput.Focus()
+	return m, textinput.Blink
+}
+
+// updateFileOp 处理文件操作输入框 (或删除确认) 的按键
+func (m MainModel) updateFileOp(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, isKey := msg.(tea.KeyMsg)
+
+	if m.FileOp == fileOpDelete {
+		if !isKey {
+			return m, nil
+		}
+		switch keyMsg.String() {
+		case "y", "Y":
+			return m.applyFileOp("")
+		case "n", "N", "esc", "q":
+			m.FileOp = ""
+			m.StatusMsg = "Cancelled."
+		}
+		return m, nil
+	}
+
+	if isKey {
+		switch keyMsg.String() {
+		case "enter":
+			return m.applyFileOp(strings.TrimSpace(m.FileInput.Value()))
+		case "esc":
+			m.FileOp = ""
+			m.StatusMsg = "Cancelled."
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.FileInput, cmd = m.FileInput.Update(msg)
+	return m, cmd
+}
+
+// applyFileOp 执行文件操作，随后保存配置并在后台刷新 Git 状态
+func (m MainModel) applyFileOp(value string) (tea.Model, tea.Cmd) {
+	op, node := m.FileOp, m.fileTarget
+	m.FileOp = ""
+	m.fileTarget = nil
+
+	var (
+		focus  *model.Node // 操作完成后光标停留的节点
+		status string
+		err    error
+	)
+	switch op {
+	case fileOpCreate:
+		// 以 "/" 结尾表示新建文件夹
+		isDir := strings.HasSuffix(value, "/")
+		if focus, err = core.CreatePath(m.RootNode, value, isDir); err == nil {
+			rel := core.NodeRelPath(m.RootNode, focus)
+			m.recordChanges("Create "+rel, core.Change{Kind: core.ChangeCreate, Path: rel, IsDir: isDir})
+			status = "Created " + rel
+		}
+
+	case fileOpRename:
+		if value == "" || strings.ContainsAny(value, `/\`) {
+			err = fmt.Errorf("invalid name %q (use [m] to move to another folder)", value)
+			break
+		}
+		oldRel := core.NodeRelPath(m.RootNode, node)
+		newRel := path.Join(path.Dir(oldRel), value)
+		if err = core.MovePath(m.RootNode, node, newRel); err == nil {
+			core.MigrateConfig(m.Config, oldRel, newRel)
+			focus = node
+			status = fmt.Sprintf("Renamed %s -> %s", oldRel, newRel)
+			m.recordChanges(status, core.Change{Kind: core.ChangeMove, Path: oldRel, To: newRel})
+		}
+
+	case fileOpMove:
+		oldRel := core.NodeRelPath(m.RootNode, node)
+		newRel := strings.TrimSuffix(value, "/")
+		// 以 "/" 结尾或指向已有文件夹时，移动到该文件夹中
+		if target := m.findRel(newRel); strings.HasSuffix(value, "/") || (target != nil && target.IsDir && !target.Ghost) {
+			newRel = path.Join(newRel, node.Name)
+		}
+		if err = core.MovePath(m.RootNode, node, newRel); err == nil {
+			core.MigrateConfig(m.Config, oldRel, newRel)
+			focus = node
+			status = fmt.Sprintf("Moved %s -> %s", oldRel, newRel)
+			m.recordChanges(status, core.Change{Kind: core.ChangeMove, Path: oldRel, To: newRel})
+		}
+
+	case fileOpCopy:
+		if focus, err = core.CopyPath(m.RootNode, node, value, m.WalkOpts); err == nil {
+			from, to := core.NodeRelPath(m.RootNode, node), core.NodeRelPath(m.RootNode, focus)
+			status = fmt.Sprintf("Copied %s -> %s", from, to)
+			m.recordChanges(status, core.Change{Kind: core.ChangeCopy, Path: from, To: to})
+		}
+
+	case fileOpDelete:
+		if node == nil {
+			status, err = m.deleteSelected()
+			break
+		}
+		change := core.DeleteChange(m.RootNode, node)
+		if change.Trash, err = core.TrashPath(m.RootNode, node); err == nil {
+			core.MigrateConfig(m.Config, change.Path, "")
+			status = fmt.Sprintf("Deleted %s (moved to %s, [u] to undo)", change.Path, change.Trash)
+			m.recordChanges("Delete "+change.Path, change)
+		}
+	}
+
+	if err != nil {
+		m.StatusMsg = "Error: " + err.Error()
+		return m, nil
+	}
+
+	// 树的结构变了
+	m.resort()
+	m.invalidateSearch()
+	if focus != nil {
+		m.jumpTo(focus)
+	} else {
+		m.clampCursor()
+	}
+	m.StatusMsg = status
+	return m, tea.Batch(m.triggerDebouncedSave(), m.refreshGitCmd(status))
+}
+
+// deleteSelected 把所有选中的节点移到回收目录并清空选择，遇到错误时停止
+func (m *MainModel) deleteSelected() (string, error) {
+	var changes []core.Change
+	defer func() {
+		// 出错时已删除的部分同样可以撤销
+		m.recordChanges(fmt.Sprintf("Delete %d items", len(changes)), changes...)
+	}()
+
+	for _, node := range m.selectionRoots() {
+		if node.Ghost {
+			continue
+		}
+		change := core.DeleteChange(m.RootNode, node)
+		var err error
+		if change.Trash, err = core.TrashPath(m.RootNode, node); err != nil {
+			// 已删除的节点仍然要从选择中去掉
+			*m = m.clearSelection()
+			return "", fmt.Errorf("deleted %d items, then failed on %s: %v", len(changes), change.Path, err)
+		}
+		core.MigrateConfig(m.Config, change.Path, "")
+		changes = append(changes, change)
+	}
+	*m = m.clearSelection()
+	return fmt.Sprintf("Deleted %d items (moved to the gentr trash folder, [u] to undo)", len(changes)), nil
+}
+
+// findRel 按相对路径查找树中的节点
+func (m MainModel) findRel(relPath string) *model.Node {
+	node := m.RootNode
+	for _, part := range strings.Split(relPath, "/") {
+		var found *model.Node
+		for _, child := range node.Children {
+			if child.Name == part {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		node = found
+	}
+	return node
+}
+
+// deletePrompt 返回删除确认的提示
+func (m MainModel) deletePrompt() string {
+	if m.fileTarget == nil {
+		return fmt.Sprintf("Delete %d selected items?", len(m.selectionRoots()))
+	}
+	return "Delete " + core.NodeRelPath(m.RootNode, m.fileTarget) + "?"
+}
+
+// fileOpPrompt 返回文件操作输入框上方的提示
+func (m MainModel) fileOpPrompt() string {
+	switch m.FileOp {
+	case fileOpCreate:
+		return "New file (relative to project root, end with / for a folder):"
+	case fileOpRename:
+		return "Rename " + m.fileTarget.Name + " to:"
+	case fileOpMove:
+		return "Move " + m.fileTarget.Name + " to (a folder ending with / keeps the name):"
+	case fileOpCopy:
+		return "Copy " + m.fileTarget.Name + " to:"
+	}
+	return ""
+}
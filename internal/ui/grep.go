@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// grepPreviewLines 是内容搜索时底部预览区显示的匹配行数
+const grepPreviewLines = 5
+
+// grepState 是一次内容搜索的结果
+type grepState struct {
+	pattern string
+	files   map[*model.Node]*core.GrepFile // 有匹配的文件
+	counts  map[*model.Node]int            // 每个节点 (含文件夹) 的匹配行数
+}
+
+// grepDoneMsg 携带后台内容搜索的结果 (按路径)，tag 用于丢弃过期的搜索
+type grepDoneMsg struct {
+	tag     int
+	pattern string
+	files   map[string]*core.GrepFile
+	err     error
+}
+
+// openGrepInput 打开内容搜索输入框，预填当前的搜索词
+func (m MainModel) openGrepInput() (tea.Model, tea.Cmd) {
+	m.GrepMode = true
+	if m.Grep != nil {
+		m.GrepInput.SetValue(m.Grep.pattern)
+		m.GrepInput.CursorEnd()
+	}
+	m.GrepInput.Focus()
+	return m, textinput.Blink
+}
+
+// updateGrepMode 处理内容搜索输入框的按键
+func (m MainModel) updateGrepMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			m.GrepMode = false
+			pattern := m.GrepInput.Value()
+			if pattern == "" {
+				m = m.clearGrep()
+				m.StatusMsg = "Content search cleared"
+				return m, nil
+			}
+			m.cancelGrep() // 新的搜索开始时停止上一次搜索
+			m.grepTag++
+			m.StatusMsg = "Searching contents for " + pattern + "..."
+			cmd := m.grepCmd(m.grepTag, pattern)
+			return m, cmd
+
+		case "esc":
+			m.GrepMode = false
+			m.StatusMsg = "Cancelled."
+			if m.cancelGrep() {
+				m.StatusMsg = "Content search cancelled"
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.GrepInput, cmd = m.GrepInput.Update(msg)
+	return m, cmd
+}
+
+// grepCmd 在后台搜索文件内容
+// 文件列表在当前协程中收集，后台协程只接触路径，不读取可能同时被修改的树
+func (m *MainModel) grepCmd(tag int, pattern string) tea.Cmd {
+	paths := core.GrepPaths(m.RootNode)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.grepCancel = cancel
+	return func() tea.Msg {
+		files, err := core.Grep(ctx, paths, pattern)
+		return grepDoneMsg{tag: tag, pattern: pattern, files: files, err: err}
+	}
+}
+
+// cancelGrep 停止正在进行的内容搜索，返回是否有搜索被停止
+func (m *MainModel) cancelGrep() bool {
+	if m.grepCancel == nil {
+		return false
+	}
+	m.grepCancel()
+	m.grepCancel = nil
+	return true
+}
+
+// handleGrepDone 应用内容搜索结果，并统计每个文件夹的匹配数
+func (m MainModel) handleGrepDone(msg grepDoneMsg) MainModel {
+	// 期间又发起了新的搜索或清除了搜索
+	if msg.tag != m.grepTag {
+		return m
+	}
+	m.grepCancel = nil
+	if errors.Is(msg.err, context.Canceled) {
+		return m // 已在取消时提示
+	}
+	if msg.err != nil {
+		m.StatusMsg = "Error: " + msg.err.Error()
+		return m
+	}
+
+	// 按路径对应回树中的节点 (搜索期间被移动或删除的文件不再显示)
+	state := &grepState{
+		pattern: msg.pattern,
+		files:   make(map[*model.Node]*core.GrepFile),
+		counts:  make(map[*model.Node]int),
+	}
+	unloaded := 0
+	var index func(node *model.Node)
+	index = func(node *model.Node) {
+		for _, child := range node.Children {
+			if child.Unloaded {
+				unloaded++
+			}
+			if file, ok := msg.files[child.Path]; ok && !child.IsDir && !child.Ghost {
+				state.files[child] = file
+			}
+			index(child)
+		}
+	}
+	index(m.RootNode)
+	var count func(node *model.Node) int
+	count = func(node *model.Node) int {
+		total := 0
+		if file, ok := state.files[node]; ok {
+			total = file.Count
+		}
+		for _, child := range node.Children {
+			total += count(child)
+		}
+		if total > 0 {
+			state.counts[node] = total
+		}
+		return total
+	}
+	total := count(m.RootNode)
+
+	m.Grep = state
+	m.Cursor = 0
+	m.ScrollOffset = 0
+	m.StatusMsg = fmt.Sprintf("%d matches in %d files for %q", total, len(state.files), msg.pattern)
+	if hint := partialHint(unloaded); hint != "" {
+		m.StatusMsg += " | " + hint
+	}
+	return m
+}
+
+// clearGrep 退出内容搜索过滤
+func (m MainModel) clearGrep() MainModel {
+	m.Grep = nil
+	m.cancelGrep()
+	m.grepTag++ // 丢弃尚未返回的搜索
+	m.GrepInput.SetValue("")
+	m.Cursor = 0
+	m.ScrollOffset = 0
+	return m
+}
+
+// grepBadge 返回节点的匹配数徽标，例如 " (3)"
+func (m MainModel) grepBadge(node *model.Node) string {
+	if m.Grep == nil {
+		return ""
+	}
+	if count := m.Grep.counts[node]; count > 0 {
+		return fmt.Sprintf(" (%d)", count)
+	}
+	return ""
+}
+
+// renderGrepPreview 渲染光标所在文件的匹配行，固定占用 grepPreviewLines+1 行
+func (m MainModel) renderGrepPreview() string {
+	lines := make([]string, 0, grepPreviewLines+1)
+
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	var file *core.GrepFile
+	if node != nil {
+		file = m.Grep.files[node]
+	}
+
+	if file == nil {
+		lines = append(lines, dimmedStyle.Render(fmt.Sprintf("─ Matches for %q (select a file to preview)", m.Grep.pattern)))
+	} else {
+		header := fmt.Sprintf("─ %s: %d matching lines", node.Name, file.Count)
+		lines = append(lines, dimmedStyle.Render(header))
+
+		// 行号按最大行号对齐
+		shown := file.Lines
+		if len(shown) > grepPreviewLines {
+			shown = shown[:grepPreviewLines]
+		}
+		width := len(fmt.Sprint(shown[len(shown)-1].Number))
+		for _, line := range shown {
+			number := fmt.Sprintf("%*d│ ", width, line.Number)
+			text := strings.ReplaceAll(line.Text, "\t", "    ")
+			text = truncateWidth(text, m.Width-lipgloss.Width(number)-3)
+			lines = append(lines, "  "+dimmedStyle.Render(number)+normalStyle.Render(text))
+		}
+	}
+
+	for len(lines) < grepPreviewLines+1 {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateWidth 把文本截断到指定的显示宽度，超出时以 "…" 结尾
+func truncateWidth(text string, width int) string {
+	if width <= 1 || lipgloss.Width(text) <= width {
+		return text
+	}
+
+	var sb strings.Builder
+	used := 0
+	for _, r := range text {
+		w := lipgloss.Width(string(r))
+		if used+w > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String() + "…"
+}
@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 帮助面板的分组标题
+var helpTitleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+
+// helpEntry 是帮助面板中的一个按键说明
+type helpEntry struct {
+	keys string
+	desc string
+}
+
+// helpSection 是帮助面板中的一组按键 (按模式分组)
+type helpSection struct {
+	title   string
+	entries []helpEntry
+}
+
+// helpSections 返回按模式分组的所有按键
+// 导航模式的分组由当前的按键绑定生成，其余模式的按键是固定的，自定义命令附在最后
+func (m MainModel) helpSections() []helpSection {
+	var sections []helpSection
+	for _, a := range m.Keys.actions() {
+		if !a.binding.Enabled() {
+			continue
+		}
+		if len(sections) == 0 || sections[len(sections)-1].title != a.group {
+			sections = append(sections, helpSection{title: a.group})
+		}
+		last := &sections[len(sections)-1]
+		last.entries = append(last.entries, helpEntry{a.binding.Help().Key, a.binding.Help().Desc})
+	}
+
+	sections = append(sections, []helpSection{
+		{"Search mode (/)", []helpEntry{
+			{"Tab", "Ranked results / tree"},
+			{"↑/↓  Ctrl+P/N", "Select result (ranked list)"},
+			{"Enter", "Keep filter / jump to result"},
+			{"Esc", "Clear and leave search"},
+		}},
+		{"Input (comment, paths, palette)", []helpEntry{
+			{"Enter", "Apply"},
+			{"Esc", "Cancel"},
+			{"↑/↓", "Select command (palette)"},
+			{"y / n", "Confirm / cancel delete"},
+		}},
+		{"Orphans panel", []helpEntry{
+			{keyLabel(slices.Concat(m.Keys.Up.Keys(), m.Keys.Down.Keys())), "Select entry"},
+			{keyLabel(slices.Concat([]string{"enter"}, m.Keys.Rename.Keys())), "Re-attach to a path"},
+			{keyLabel(m.Keys.Delete.Keys()), "Discard entry"},
+			{keyLabel(slices.Concat(m.Keys.Clear.Keys(), m.Keys.Orphans.Keys(), m.Keys.Quit.Keys())), "Back"},
+		}},
+		{"Mouse", []helpEntry{
+			{"Click", "Move cursor"},
+			{"Click ▶/▼", "Fold / unfold"},
+			{"Double-click", "Edit comment"},
+			{"Wheel", "Scroll"},
+		}},
+	}...)
+
+	// 用户配置与 .gentr.json 中定义的自定义命令
+	if len(m.Settings.Commands) > 0 {
+		custom := helpSection{title: "Custom commands"}
+		for _, c := range m.Settings.Commands {
+			label := keyName(c.Key)
+			if c.Key == "" {
+				label = ": only"
+			}
+			custom.entries = append(custom.entries, helpEntry{label, c.Title() + " (" + c.Source + ")"})
+		}
+		sections = append(sections, custom)
+	}
+	return sections
+}
+
+// openHelp 打开帮助面板
+func (m MainModel) openHelp() (tea.Model, tea.Cmd) {
+	m.HelpMode = true
+	m.HelpScroll = 0
+	return m, nil
+}
+
+// updateHelpMode 处理帮助面板的按键：滚动或关闭
+func (m MainModel) updateHelpMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	maxScroll := max(len(m.renderHelp())-m.viewportHeight(), 0)
+	switch {
+	case keyMsg.String() == "ctrl+c":
+		return m.quit()
+	case key.Matches(keyMsg, m.Keys.Up):
+		m.HelpScroll--
+	case key.Matches(keyMsg, m.Keys.Down):
+		m.HelpScroll++
+	case key.Matches(keyMsg, m.Keys.PageUp):
+		m.HelpScroll -= m.viewportHeight()
+	case key.Matches(keyMsg, m.Keys.PageDown):
+		m.HelpScroll += m.viewportHeight()
+	case key.Matches(keyMsg, m.Keys.Top), keyMsg.String() == m.Keys.topSequence():
+		m.HelpScroll = 0
+	case key.Matches(keyMsg, m.Keys.Bottom):
+		m.HelpScroll = maxScroll
+	default:
+		// 其余按键 (?、Esc、q 等) 关闭帮助
+		m.HelpMode = false
+		return m, nil
+	}
+	m.HelpScroll = min(max(m.HelpScroll, 0), maxScroll)
+	return m, nil
+}
+
+// renderHelp 渲染帮助面板，替代文件树区域
+// 终端足够宽时分两栏显示，每个分组不会被拆开
+func (m MainModel) renderHelp() []string {
+	sections := m.helpSections()
+
+	keyWidth := 0
+	for _, section := range sections {
+		for _, entry := range section.entries {
+			keyWidth = max(keyWidth, lipgloss.Width(entry.keys))
+		}
+	}
+
+	blocks := make([][]string, len(sections))
+	blockWidth := 0
+	for i, section := range sections {
+		block := []string{helpTitleStyle.Render(section.title)}
+		for _, entry := range section.entries {
+			line := fmt.Sprintf("  %s%s  %s", selectedStyle.Render(entry.keys),
+				strings.Repeat(" ", keyWidth-lipgloss.Width(entry.keys)), entry.desc)
+			block = append(block, line)
+			blockWidth = max(blockWidth, lipgloss.Width(line))
+		}
+		blocks[i] = append(block, "")
+	}
+
+	// 单栏
+	columnWidth := blockWidth + 2
+	if m.Width < columnWidth*2 {
+		var lines []string
+		for _, block := range blocks {
+			lines = append(lines, block...)
+		}
+		return lines
+	}
+
+	// 两栏：按顺序填充左栏，直到超过总行数的一半
+	total := 0
+	for _, block := range blocks {
+		total += len(block)
+	}
+	var left, right []string
+	for _, block := range blocks {
+		if len(left) < (total+1)/2 && len(right) == 0 {
+			left = append(left, block...)
+		} else {
+			right = append(right, block...)
+		}
+	}
+
+	lines := make([]string, max(len(left), len(right)))
+	for i := range lines {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		lines[i] = l + strings.Repeat(" ", max(columnWidth-lipgloss.Width(l), 0)) + r
+	}
+	return lines
+}
@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 预览区的语法着色样式 (Nord 配色，与 Git 标记一致)
+var (
+	syntaxKeywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#81A1C1")).Bold(true)
+	syntaxStringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#A3BE8C"))
+	syntaxNumberStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#B48EAD"))
+	syntaxCommentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Italic(true)
+)
+
+// syntax 描述一种语言的简单着色规则：行注释前缀与关键字
+// 只做按行的词法着色，不处理跨行的块注释与字符串
+type syntax struct {
+	comments []string
+	keywords map[string]bool
+}
+
+func newSyntax(comments []string, keywords string) *syntax {
+	s := &syntax{comments: comments, keywords: make(map[string]bool)}
+	for _, kw := range strings.Fields(keywords) {
+		s.keywords[kw] = true
+	}
+	return s
+}
+
+var (
+	goSyntax = newSyntax([]string{"//"}, "break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false")
+	jsSyntax = newSyntax([]string{"//"}, "async await break case catch class const continue default delete do else export extends false finally for from function if import in instanceof let new null return super switch this throw true try typeof undefined var void while yield interface type enum implements")
+	pySyntax = newSyntax([]string{"#"}, "and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield self")
+	rsSyntax = newSyntax([]string{"//"}, "as async await break const continue crate else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while")
+	cSyntax  = newSyntax([]string{"//"}, "auto break case char class const continue default do double else enum extern false float for goto if include define int long namespace new nullptr private protected public return short signed sizeof static struct switch template this true typedef union unsigned using virtual void volatile while")
+	shSyntax = newSyntax([]string{"#"}, "if then else elif fi case esac for while until do done in function return local export set unset echo exit")
+	// 配置类文件只着色注释、字符串与数字
+	hashSyntax  = newSyntax([]string{"#"}, "true false null")
+	sqlSyntax   = newSyntax([]string{"--"}, "SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AND OR NOT NULL AS select from where insert into values update set delete create table drop alter join left right inner outer on group by order having limit and or not null as")
+	plainSyntax = newSyntax(nil, "true false null")
+)
+
+// syntaxFor 根据文件扩展名选择着色规则，未知类型返回 nil (不着色)
+func syntaxFor(name string) *syntax {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".go":
+		return goSyntax
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs", ".java", ".kt", ".swift", ".cs", ".dart", ".scala":
+		return jsSyntax
+	case ".py":
+		return pySyntax
+	case ".rs":
+		return rsSyntax
+	case ".c", ".h", ".cpp", ".cc", ".hpp", ".m":
+		return cSyntax
+	case ".sh", ".bash", ".zsh":
+		return shSyntax
+	case ".yml", ".yaml", ".toml", ".ini", ".conf", ".cfg", ".rb", ".r", ".pl", ".mk":
+		return hashSyntax
+	case ".sql", ".lua":
+		return sqlSyntax
+	case ".json":
+		return plainSyntax
+	}
+	switch strings.ToLower(name) {
+	case "makefile", "dockerfile", ".gitignore", ".env":
+		return hashSyntax
+	}
+	return nil
+}
+
+// highlightLine 对一行代码做简单的词法着色
+func (s *syntax) highlightLine(line string) string {
+	if s == nil {
+		return normalStyle.Render(line)
+	}
+
+	var sb strings.Builder
+	runes := []rune(line)
+	plainStart := 0
+	flush := func(end int) {
+		if end > plainStart {
+			sb.WriteString(normalStyle.Render(string(runes[plainStart:end])))
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+
+		// 行注释：直到行尾
+		if s.commentAt(runes, i) {
+			flush(i)
+			sb.WriteString(syntaxCommentStyle.Render(string(runes[i:])))
+			return sb.String()
+		}
+
+		// 字符串："..."、'...'、`...`，支持反斜杠转义，未闭合时到行尾为止
+		if c == '"' || c == '\'' || c == '`' {
+			end := i + 1
+			for end < len(runes) && runes[end] != c {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(runes) {
+				end++
+			} else {
+				end = len(runes)
+			}
+			flush(i)
+			sb.WriteString(syntaxStringStyle.Render(string(runes[i:end])))
+			i, plainStart = end, end
+			continue
+		}
+
+		// 标识符与数字：整体判断，避免把 "x1" 中的 1 当作数字
+		if isWordRune(c) {
+			end := i
+			for end < len(runes) && (isWordRune(runes[end]) || (unicode.IsDigit(runes[i]) && runes[end] == '.')) {
+				end++
+			}
+			word := string(runes[i:end])
+			var style *lipgloss.Style
+			if unicode.IsDigit(c) {
+				style = &syntaxNumberStyle
+			} else if s.keywords[word] {
+				style = &syntaxKeywordStyle
+			}
+			if style != nil {
+				flush(i)
+				sb.WriteString(style.Render(word))
+				plainStart = end
+			}
+			i = end
+			continue
+		}
+		i++
+	}
+	flush(len(runes))
+	return sb.String()
+}
+
+// commentAt 判断第 i 个字符是否是行注释的开头
+func (s *syntax) commentAt(runes []rune, i int) bool {
+	for _, prefix := range s.comments {
+		p := []rune(prefix)
+		if i+len(p) > len(runes) || string(runes[i:i+len(p)]) != prefix {
+			continue
+		}
+		// "#" 只在行首或空白之后才算注释，避免误判 "a#b" 这样的写法
+		if prefix == "#" && i > 0 && !unicode.IsSpace(runes[i-1]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
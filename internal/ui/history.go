@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snapshot 在修改节点状态之前调用，配合 recordState 记录可撤销的操作
+func (m MainModel) snapshot() map[string]core.NodeConfig {
+	return core.SnapshotState(m.RootNode)
+}
+
+// recordState 比较修改前的快照，把状态变化记录为一次可撤销的操作
+func (m MainModel) recordState(label string, before map[string]core.NodeConfig) {
+	if m.History == nil {
+		return
+	}
+	m.History.Record(label, core.DiffState(before, m.snapshot()))
+}
+
+// recordChanges 记录一次文件操作
+func (m MainModel) recordChanges(label string, changes ...core.Change) {
+	if m.History == nil {
+		return
+	}
+	m.History.Record(label, changes)
+}
+
+// undo 撤销 (redo 为 false 时) 或重做最近一次操作
+func (m MainModel) undo(redo bool) (tea.Model, tea.Cmd) {
+	if m.History == nil {
+		return m, nil
+	}
+
+	var (
+		entry        *core.HistoryEntry
+		err          error
+		action, verb = "undo", "Undid"
+	)
+	if redo {
+		action, verb = "redo", "Redid"
+		entry, err = m.History.RedoLast(m.RootNode, m.Config, m.WalkOpts)
+	} else {
+		entry, err = m.History.UndoLast(m.RootNode, m.Config, m.WalkOpts)
+	}
+
+	if entry == nil {
+		m.StatusMsg = "Nothing to " + action
+		return m, nil
+	}
+
+	// 树的结构或节点状态变了
+	if entry.HasFileChanges() {
+		m.resort()
+	}
+	m.invalidateSearch()
+	m.clampCursor()
+
+	status := fmt.Sprintf("%s: %s", verb, entry.Label)
+	if err != nil {
+		// 失败的条目已被丢弃，之前已经生效的修改保留
+		status = fmt.Sprintf("Could not %s %q (dropped from history): %v", action, entry.Label, err)
+	}
+	m.StatusMsg = status
+
+	// 撤销可能重新展开了尚未读取的文件夹
+	cmds := []tea.Cmd{m.triggerDebouncedSave(), m.loadExpanded()}
+	if entry.HasFileChanges() {
+		cmds = append(cmds, m.refreshGitCmd(status))
+	}
+	return m, tea.Batch(cmds...)
+}
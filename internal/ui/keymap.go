@@ -0,0 +1,315 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// KeyMap 是导航模式下的按键绑定，可以在用户配置或 .gentr.json 的 "keys" 中修改
+// 输入框、搜索、命令面板等模式内的按键 (Enter、Esc、↑/↓) 是固定的
+type KeyMap struct {
+	Up, Down, PageUp, PageDown, Top, Bottom                    key.Binding
+	Parent, Child, PrevSibling, NextSibling                    key.Binding
+	GoTo, Palette, Help, Quit                                  key.Binding
+	Fold, ExpandAll, CollapseAll, Hide, Comment, Preview       key.Binding
+	Undo, Redo                                                 key.Binding
+	Select, SelectDown, SelectRange, SelectMatching, CopyPaths key.Binding
+	Clear                                                      key.Binding
+	Edit, Shell, Create, Rename, Move, Copy, Delete            key.Binding
+	Search, Grep, GitFilter, DiffRef, Orphans                  key.Binding
+	CopyTree, SaveText, SaveSVG, SaveJSON                      key.Binding
+}
+
+// keyAction 是一个可配置的动作：配置中的名称、帮助面板中的分组与对应的绑定
+type keyAction struct {
+	name    string
+	group   string
+	binding *key.Binding
+}
+
+// newBinding 创建绑定，帮助中显示的按键由 keys 生成
+func newBinding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keyLabel(keys), desc))
+}
+
+// DefaultKeyMap 返回默认的按键绑定
+func DefaultKeyMap() KeyMap {
+	k := KeyMap{
+		Up:          newBinding("Move up", "up", "k"),
+		Down:        newBinding("Move down", "down", "j"),
+		PageUp:      newBinding("Page up", "pgup", "ctrl+b"),
+		PageDown:    newBinding("Page down", "pgdown", "ctrl+f"),
+		Top:         newBinding("Jump to top", "gg", "home"),
+		Bottom:      newBinding("Jump to bottom", "G", "end"),
+		Parent:      newBinding("Jump to parent", "h", "left"),
+		Child:       newBinding("Jump into folder", "l", "right"),
+		NextSibling: newBinding("Next sibling", "]"),
+		PrevSibling: newBinding("Previous sibling", "["),
+		GoTo:        newBinding("Go to path (fuzzy)", "ctrl+g"),
+		Palette:     newBinding("Command palette", ":"),
+		Help:        newBinding("Show this help", "?"),
+		Quit:        newBinding("Quit", "q"),
+
+		Fold:        newBinding("Fold / unfold folder", " "),
+		ExpandAll:   newBinding("Expand subtree", "+", "="),
+		CollapseAll: newBinding("Collapse subtree", "-"),
+		Hide:        newBinding("Hide / show", "enter"),
+		Comment:     newBinding("Add / edit comment", "i"),
+		Preview:     newBinding("Toggle preview pane", "tab"),
+		Undo:        newBinding("Undo", "u"),
+		Redo:        newBinding("Redo", "ctrl+r"),
+
+		Select:         newBinding("Select", "v"),
+		SelectDown:     newBinding("Select and move down", "x"),
+		SelectRange:    newBinding("Select range", "V"),
+		SelectMatching: newBinding("Select all matching", "*"),
+		CopyPaths:      newBinding("Copy selected paths", "Y"),
+		Clear:          newBinding("Clear selection / search", "esc"),
+
+		Edit:   newBinding("Open in $VISUAL / $EDITOR", "e"),
+		Shell:  newBinding("Open a shell in the folder", "!"),
+		Create: newBinding("New file (dir/ for a folder)", "a"),
+		Rename: newBinding("Rename", "r"),
+		Move:   newBinding("Move", "m"),
+		Copy:   newBinding("Copy", "y"),
+		Delete: newBinding("Delete (to gentr trash)", "d"),
+
+		Search:    newBinding("Fuzzy search", "/"),
+		Grep:      newBinding("Search file contents", "F"),
+		GitFilter: newBinding("Toggle git change filter", "f"),
+		DiffRef:   newBinding("Compare against a git ref", "b"),
+		Orphans:   newBinding("Review orphaned annotations", "O"),
+
+		CopyTree: newBinding("Copy tree to clipboard", "c"),
+		SaveText: newBinding("Save to .txt file", "s"),
+		SaveSVG:  newBinding("Save SVG (dark & light)", "p"),
+		SaveJSON: newBinding("Save to .json file", "J"),
+	}
+	return k
+}
+
+// actions 按帮助面板中的顺序返回所有可配置的动作
+func (k *KeyMap) actions() []keyAction {
+	return []keyAction{
+		{"up", "Navigation", &k.Up},
+		{"down", "Navigation", &k.Down},
+		{"page_up", "Navigation", &k.PageUp},
+		{"page_down", "Navigation", &k.PageDown},
+		{"top", "Navigation", &k.Top},
+		{"bottom", "Navigation", &k.Bottom},
+		{"parent", "Navigation", &k.Parent},
+		{"child", "Navigation", &k.Child},
+		{"prev_sibling", "Navigation", &k.PrevSibling},
+		{"next_sibling", "Navigation", &k.NextSibling},
+		{"go_to", "Navigation", &k.GoTo},
+		{"palette", "Navigation", &k.Palette},
+		{"help", "Navigation", &k.Help},
+		{"quit", "Navigation", &k.Quit},
+
+		{"fold", "Tree", &k.Fold},
+		{"expand_all", "Tree", &k.ExpandAll},
+		{"collapse_all", "Tree", &k.CollapseAll},
+		{"hide", "Tree", &k.Hide},
+		{"comment", "Tree", &k.Comment},
+		{"preview", "Tree", &k.Preview},
+		{"undo", "Tree", &k.Undo},
+		{"redo", "Tree", &k.Redo},
+
+		{"select", "Selection", &k.Select},
+		{"select_down", "Selection", &k.SelectDown},
+		{"select_range", "Selection", &k.SelectRange},
+		{"select_matching", "Selection", &k.SelectMatching},
+		{"copy_paths", "Selection", &k.CopyPaths},
+		{"clear", "Selection", &k.Clear},
+
+		{"edit", "Files", &k.Edit},
+		{"shell", "Files", &k.Shell},
+		{"create", "Files", &k.Create},
+		{"rename", "Files", &k.Rename},
+		{"move", "Files", &k.Move},
+		{"copy", "Files", &k.Copy},
+		{"delete", "Files", &k.Delete},
+
+		{"search", "Search & Git", &k.Search},
+		{"grep", "Search & Git", &k.Grep},
+		{"git_filter", "Search & Git", &k.GitFilter},
+		{"diff_ref", "Search & Git", &k.DiffRef},
+		{"orphans", "Search & Git", &k.Orphans},
+
+		{"copy_tree", "Export", &k.CopyTree},
+		{"save_text", "Export", &k.SaveText},
+		{"save_svg", "Export", &k.SaveSVG},
+		{"save_json", "Export", &k.SaveJSON},
+	}
+}
+
+// LoadKeyMap 在默认绑定的基础上应用配置中的 "keys" (动作名 -> 按键列表)，并检查冲突
+// 动作的按键列表为空时表示取消该动作的绑定
+func LoadKeyMap(overrides map[string][]string) (KeyMap, error) {
+	k := DefaultKeyMap()
+	if len(overrides) == 0 {
+		return k, nil
+	}
+
+	byName := make(map[string]*key.Binding)
+	var names []string
+	for _, a := range k.actions() {
+		byName[a.name] = a.binding
+		names = append(names, a.name)
+	}
+
+	// 按名称排序，保证错误信息稳定
+	var overridden []string
+	for name := range overrides {
+		overridden = append(overridden, name)
+	}
+	sort.Strings(overridden)
+
+	for _, name := range overridden {
+		binding, ok := byName[name]
+		if !ok {
+			return k, fmt.Errorf("unknown action %q in keys (available: %s)", name, strings.Join(names, ", "))
+		}
+		keys := overrides[name]
+		for _, s := range keys {
+			if s == "" {
+				return k, fmt.Errorf("empty key for action %q", name)
+			}
+		}
+		if len(keys) == 0 {
+			binding.Unbind()
+			continue
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(keyLabel(keys), binding.Help().Desc)
+	}
+	return k, k.validate()
+}
+
+// validate 检查同一个按键是否被绑定到多个动作 (Ctrl+C 保留给退出)
+func (k *KeyMap) validate() error {
+	owner := map[string]string{"ctrl+c": "quit (reserved)"}
+	for _, a := range k.actions() {
+		for _, s := range a.binding.Keys() {
+			if other, ok := owner[s]; ok && other != a.name {
+				return fmt.Errorf("key %q is bound to both %s and %s", s, other, a.name)
+			}
+			owner[s] = a.name
+		}
+	}
+	return nil
+}
+
+// ValidateCommands 检查自定义命令的按键：不能与内置按键或其他命令重复，否则命令会被静默遮蔽
+func (k KeyMap) ValidateCommands(commands []core.Command) error {
+	owner := map[string]string{"ctrl+c": "quit (reserved)"}
+	for _, a := range k.actions() {
+		for _, s := range a.binding.Keys() {
+			owner[s] = a.name
+		}
+	}
+	for _, c := range commands {
+		if c.Key == "" {
+			continue
+		}
+		if other, ok := owner[c.Key]; ok {
+			return fmt.Errorf("key %q of command %q is already bound to %s", c.Key, c.Title(), other)
+		}
+		owner[c.Key] = fmt.Sprintf("command %q", c.Title())
+	}
+	return nil
+}
+
+// topSequence 返回 Top 中连按两次的按键 (例如 "gg" 中的 "g")，没有配置时为空
+// 如果这个按键同时绑定了其它操作，该操作要等待片刻 (确认没有连按) 才生效
+func (k KeyMap) topSequence() string {
+	for _, s := range k.Top.Keys() {
+		if r := []rune(s); len(r) == 2 && r[0] == r[1] {
+			return string(r[0])
+		}
+	}
+	return ""
+}
+
+// keyNames 是按键在帮助中显示的名称
+var keyNames = map[string]string{
+	"up": "↑", "down": "↓", "left": "←", "right": "→",
+	" ": "Space", "enter": "Enter", "tab": "Tab", "esc": "Esc",
+	"pgup": "PgUp", "pgdown": "PgDn", "home": "Home", "end": "End",
+}
+
+// keyName 返回单个按键的显示名称，例如 "ctrl+g" -> "Ctrl+G"
+func keyName(s string) string {
+	if name, ok := keyNames[s]; ok {
+		return name
+	}
+	if rest, ok := strings.CutPrefix(s, "ctrl+"); ok {
+		return "Ctrl+" + strings.ToUpper(rest)
+	}
+	if rest, ok := strings.CutPrefix(s, "alt+"); ok {
+		return "Alt+" + rest
+	}
+	return s
+}
+
+// keyLabel 返回一组按键的显示名称，以 "/" 分隔
+func keyLabel(keys []string) string {
+	names := make([]string, len(keys))
+	for i, s := range keys {
+		names[i] = keyName(s)
+	}
+	return strings.Join(names, "/")
+}
+
+// shortKey 返回绑定的第一个按键的简短名称 (用于底部提示)，未绑定时返回空
+func shortKey(b key.Binding) string {
+	if !b.Enabled() {
+		return ""
+	}
+	switch s := b.Keys()[0]; s {
+	case " ":
+		return "Spc"
+	case "enter":
+		return "Ent"
+	default:
+		return keyName(s)
+	}
+}
+
+// keyHint 生成底部提示，例如 "[a/r/m] File Ops"，所有绑定都未设置时返回空
+func keyHint(desc string, bindings ...key.Binding) string {
+	var keys []string
+	for _, b := range bindings {
+		if s := shortKey(b); s != "" {
+			keys = append(keys, s)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(keys, "/") + "] " + desc
+}
+
+// joinHints 用两个空格连接底部提示，超出宽度的提示被省略，空提示被跳过
+func joinHints(width int, hints []string) string {
+	var sb strings.Builder
+	for _, hint := range hints {
+		if hint == "" {
+			continue
+		}
+		sep := ""
+		if sb.Len() > 0 {
+			sep = "  "
+		}
+		if width > 0 && lipgloss.Width(sb.String()+sep+hint) > width {
+			break
+		}
+		sb.WriteString(sep + hint)
+	}
+	return sb.String()
+}
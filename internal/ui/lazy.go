@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lazyGitDelay 内连续读取多个文件夹时，只在最后一次读取之后重新加载一次 Git 状态
+const lazyGitDelay = time.Second
+
+// lazyGitMsg 在读取文件夹之后延时发送，tag 不是最新时丢弃 (期间又读取了其它文件夹)
+type lazyGitMsg struct {
+	tag    int
+	status string
+}
+
+// dirLoadedMsg 携带懒加载模式下后台读取的文件夹内容
+type dirLoadedMsg struct {
+	node     *model.Node
+	path     string // 发起读取时节点的路径，期间被移动或重命名时丢弃结果
+	children []*model.Node
+	limits   core.LimitInfo
+	err      error
+}
+
+// loadExpanded 为所有已展开但尚未读取的文件夹发起后台读取 (懒加载模式)
+// 在展开文件夹的操作之后调用；读取中的文件夹显示加载指示，第一次读取时启动 spinner
+func (m *MainModel) loadExpanded() tea.Cmd {
+	if !m.WalkOpts.Lazy {
+		return nil
+	}
+	var cmds []tea.Cmd
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		for _, child := range node.Children {
+			if !child.IsDir || child.Collapsed {
+				continue
+			}
+			if child.Unloaded {
+				if !m.loading[child] {
+					m.loading[child] = true
+					cmds = append(cmds, m.loadDirCmd(child))
+				}
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(m.RootNode)
+
+	if len(cmds) > 0 && !m.spinning {
+		m.spinning = true
+		cmds = append(cmds, m.Spinner.Tick)
+	}
+	return tea.Batch(cmds...)
+}
+
+// loadDirCmd 在后台读取一个文件夹的直接子节点
+func (m MainModel) loadDirCmd(node *model.Node) tea.Cmd {
+	loader, path := m.dirLoader, node.Path
+	return func() tea.Msg {
+		children, limits, err := loader.Load(path)
+		return dirLoadedMsg{node: node, path: path, children: children, limits: limits, err: err}
+	}
+}
+
+// handleDirLoaded 把读取到的子节点挂到树上，并稍后重新加载 Git 状态 (新节点需要状态与幽灵节点)
+func (m MainModel) handleDirLoaded(msg dirLoadedMsg) (MainModel, tea.Cmd) {
+	node := msg.node
+	delete(m.loading, node)
+	if !node.Unloaded || node.Path != msg.path {
+		return m, nil // 期间被移动、重命名或重新扫描
+	}
+
+	rel := core.NodeRelPath(m.RootNode, node)
+	node.Unloaded = false
+	if msg.err != nil {
+		m.StatusMsg = fmt.Sprintf("Error reading %s/: %v", rel, msg.err)
+		return m, nil
+	}
+
+	idx := 0
+	current := m.getNodeAtCursor(m.RootNode.Children, &idx)
+
+	// 读取期间在文件夹中新建或移入的节点保留原来的对象 (选择、历史记录仍然指向它们)
+	// 幽灵节点丢弃，由随后的 Git 刷新重新插入
+	existing := make(map[string]*model.Node)
+	for _, child := range node.Children {
+		if !child.Ghost {
+			existing[child.Name] = child
+		}
+	}
+	for i, child := range msg.children {
+		if old, ok := existing[child.Name]; ok {
+			msg.children[i] = old
+		}
+	}
+	node.Children = msg.children
+	m.Config.ApplyLoaded(m.RootPath, node.Children)
+
+	m.resort()
+	m.invalidateSearch()
+	if current != nil {
+		m.jumpTo(current)
+	}
+
+	status := fmt.Sprintf("Loaded %s/ (%d entries)", rel, len(node.Children))
+	if msg.limits.Reached() {
+		m.Limits = m.Limits.Merge(msg.limits)
+		status = fmt.Sprintf("Loaded %s/: showing the first %d entries (--max-files)", rel, len(node.Children))
+	}
+	m.StatusMsg = status
+
+	// 逐个展开文件夹时不必每次都运行 git，等停下来之后再刷新
+	m.lazyGitTag++
+	tag := m.lazyGitTag
+	return m, tea.Tick(lazyGitDelay, func(time.Time) tea.Msg {
+		return lazyGitMsg{tag: tag, status: status}
+	})
+}
+
+// handleLazyGit 在最后一次读取文件夹之后重新加载 Git 状态
+func (m MainModel) handleLazyGit(msg lazyGitMsg) (MainModel, tea.Cmd) {
+	if msg.tag != m.lazyGitTag {
+		return m, nil
+	}
+	return m, m.refreshGitCmd(msg.status)
+}
+
+// unloadedCount 统计树中尚未读取的文件夹 (懒加载模式)，它们的内容不参与过滤
+func (m MainModel) unloadedCount() int {
+	if !m.WalkOpts.Lazy {
+		return 0
+	}
+	count := 0
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		for _, child := range node.Children {
+			if child.Unloaded {
+				count++
+			}
+			walk(child)
+		}
+	}
+	walk(m.RootNode)
+	return count
+}
+
+// partialHint 在有文件夹尚未读取时提示过滤结果可能不完整
+func partialHint(unloaded int) string {
+	if unloaded == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d folders not loaded yet, results may be partial", unloaded)
+}
+
+// updateSpinner 推进加载指示的动画，没有读取中的文件夹时停止
+func (m MainModel) updateSpinner(msg spinner.TickMsg) (MainModel, tea.Cmd) {
+	if len(m.loading) == 0 {
+		m.spinning = false
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.Spinner, cmd = m.Spinner.Update(msg)
+	return m, cmd
+}
+
+// loadingBadge 返回读取中的文件夹的加载指示，例如 " ⣾ loading…"
+func (m MainModel) loadingBadge(node *model.Node) string {
+	if !m.loading[node] {
+		return ""
+	}
+	return " " + m.Spinner.View() + " loading…"
+}
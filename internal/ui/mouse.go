@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// wheelStep 是滚轮每一格滚动的行数
+	wheelStep = 3
+	// doubleClickInterval 内在同一行的两次点击视为双击
+	doubleClickInterval = 400 * time.Millisecond
+)
+
+// updateMouse 把鼠标事件映射到光标与滚动：单击选中、点击 ▶/▼ 折叠、滚轮滚动、双击编辑注释
+func (m MainModel) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.scrollBy(-wheelStep)
+		return m, nil
+
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.scrollBy(wheelStep)
+		return m, nil
+
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		return m.click(msg.X, msg.Y)
+	}
+	return m, nil
+}
+
+// click 处理左键单击，x/y 是终端中的坐标 (从 0 开始)
+func (m MainModel) click(x, y int) (tea.Model, tea.Cmd) {
+	// 分栏预览时只响应左侧文件树
+	if m.previewVisible() && x >= m.treeWidth() {
+		return m, nil
+	}
+
+	// 视口上方是标题等固定行
+	row := y - m.headerHeight()
+	if row < 0 || row >= m.viewportHeight() {
+		return m, nil
+	}
+	index := m.ScrollOffset + row
+	node, depth := m.nodeAtRow(index)
+	if node == nil {
+		return m, nil
+	}
+
+	now := time.Now()
+	doubleClick := index == m.lastClickRow && now.Sub(m.lastClick) < doubleClickInterval
+	m.lastClick, m.lastClickRow = now, index
+	m.Cursor = index
+	m.StatusMsg = ""
+
+	// 图标所在的列：光标指示符 (2) + 每层缩进 (4) + 连接符 (4)
+	iconX := 2 + depth*4 + 4
+	if node.IsDir && x >= iconX && x < iconX+2 {
+		before := m.snapshot()
+		node.Collapsed = !node.Collapsed
+		m.recordState("Collapse/expand folder", before)
+		m.clampCursor()
+		load := m.loadExpanded()
+		return m, tea.Batch(m.triggerDebouncedSave(), load)
+	}
+
+	// 双击编辑注释，与 'i' 键相同
+	if doubleClick {
+		m.lastClick = time.Time{}
+		m.InputMode = true
+		m.TextInput.SetValue(node.Annotation)
+		return m, textinput.Blink
+	}
+	return m, nil
+}
+
+// scrollBy 滚动视口，光标跟随保持在视口之内
+func (m *MainModel) scrollBy(delta int) {
+	total := m.countVisibleNodes(m.RootNode.Children)
+	vpHeight := m.viewportHeight()
+
+	offset := m.ScrollOffset + delta
+	if offset > total-vpHeight {
+		offset = total - vpHeight
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	m.ScrollOffset = offset
+
+	if m.Cursor < offset {
+		m.Cursor = offset
+	} else if m.Cursor >= offset+vpHeight {
+		m.Cursor = offset + vpHeight - 1
+	}
+}
+
+// nodeAtRow 返回可见列表中第 index 行的节点及其深度 (根目录的子节点为 0)
+func (m MainModel) nodeAtRow(index int) (*model.Node, int) {
+	row := 0
+	var walk func(children []*model.Node, depth int) (*model.Node, int)
+	walk = func(children []*model.Node, depth int) (*model.Node, int) {
+		for _, child := range children {
+			if !m.shouldShow(child) {
+				continue
+			}
+			if row == index {
+				return child, depth
+			}
+			row++
+			if child.IsDir && (!child.Collapsed || m.filtering()) {
+				if node, d := walk(child.Children, depth+1); node != nil {
+					return node, d
+				}
+			}
+		}
+		return nil, 0
+	}
+	return walk(m.RootNode.Children, 0)
+}
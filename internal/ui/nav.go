@@ -0,0 +1,318 @@
+package ui
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pendingGTimeout 内连按两次 Top 序列的按键视为跳到顶部 (例如 gg)，否则按单键处理
+const pendingGTimeout = 300 * time.Millisecond
+
+// pendingGMsg 在按下序列的第一个键之后等待第二个键超时时发送
+type pendingGMsg struct {
+	tag int
+}
+
+// treeRow 是可见列表中的一行
+type treeRow struct {
+	node  *model.Node
+	depth int // 根目录的子节点为 0
+}
+
+// visibleRows 按显示顺序返回当前可见的节点及其深度，与 getNodeAtCursor 的遍历规则一致
+func (m MainModel) visibleRows() []treeRow {
+	var rows []treeRow
+	var walk func(children []*model.Node, depth int)
+	walk = func(children []*model.Node, depth int) {
+		for _, child := range children {
+			if !m.shouldShow(child) {
+				continue
+			}
+			rows = append(rows, treeRow{node: child, depth: depth})
+			if child.IsDir && (!child.Collapsed || m.filtering()) {
+				walk(child.Children, depth+1)
+			}
+		}
+	}
+	walk(m.RootNode.Children, 0)
+	return rows
+}
+
+// setCursor 把光标移到第 index 行，并保持在视口之内
+func (m *MainModel) setCursor(index int) {
+	m.moveCursor(index - m.Cursor)
+}
+
+// pressG 处理 Top 序列的按键 (例如 gg 中的 g)：等待片刻看是否连按
+func (m MainModel) pressG(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.pendingG {
+		// 第二次按下：跳到顶部，并取消等待中的单键操作
+		m.pendingG = false
+		m.gTag++
+		m.setCursor(0)
+		return m, nil
+	}
+
+	m.pendingG = true
+	m.pendingKey = msg
+	m.gTag++
+	tag := m.gTag
+	return m, tea.Tick(pendingGTimeout, func(time.Time) tea.Msg {
+		return pendingGMsg{tag: tag}
+	})
+}
+
+// handlePendingG 在等待超时后按单键处理 (序列的按键同时绑定了其它操作时)
+func (m MainModel) handlePendingG(msg pendingGMsg) (tea.Model, tea.Cmd) {
+	if !m.pendingG || msg.tag != m.gTag {
+		return m, nil
+	}
+	return m.flushPendingKey()
+}
+
+// flushPendingKey 执行等待中的单键操作 (超时或被其它按键打断时)
+func (m MainModel) flushPendingKey() (tea.Model, tea.Cmd) {
+	m.pendingG = false
+	m.gTag++
+	m.replayingKey = true
+	next, cmd := m.Update(m.pendingKey)
+	if main, ok := next.(MainModel); ok {
+		main.replayingKey = false
+		return main, cmd
+	}
+	return next, cmd
+}
+
+// toggleGitMode 切换 Git 过滤
+func (m MainModel) toggleGitMode() MainModel {
+	m.GitMode = !m.GitMode
+	m.Cursor = 0 // 列表变了，重置光标
+	m.ScrollOffset = 0
+	if m.GitMode {
+		m.StatusMsg = "Git Filter: ON (Showing changed files)"
+		if hint := partialHint(m.unloadedCount()); hint != "" {
+			m.StatusMsg += " | " + hint
+		}
+	} else {
+		m.StatusMsg = "Git Filter: OFF"
+	}
+	return m
+}
+
+// jumpToParent 把光标移到所在节点的父文件夹
+func (m *MainModel) jumpToParent() {
+	rows := m.visibleRows()
+	if m.Cursor >= len(rows) {
+		return
+	}
+	depth := rows[m.Cursor].depth
+	for i := m.Cursor - 1; i >= 0; i-- {
+		if rows[i].depth < depth {
+			m.setCursor(i)
+			return
+		}
+	}
+}
+
+// jumpToChild 进入光标所在的文件夹 (折叠时先展开)，移到第一个子节点
+func (m MainModel) jumpToChild() (MainModel, tea.Cmd) {
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	if node == nil || !node.IsDir {
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if node.Collapsed && !m.filtering() {
+		before := m.snapshot()
+		node.Collapsed = false
+		m.recordState("Expand folder", before)
+		cmd = tea.Batch(m.triggerDebouncedSave(), m.loadExpanded())
+	}
+
+	rows := m.visibleRows()
+	if next := m.Cursor + 1; next < len(rows) && rows[next].depth > rows[m.Cursor].depth {
+		m.setCursor(next)
+	}
+	return m, cmd
+}
+
+// jumpToSibling 移到下一个 (delta 为 1) 或上一个 (delta 为 -1) 同级节点
+func (m *MainModel) jumpToSibling(delta int) {
+	rows := m.visibleRows()
+	if m.Cursor >= len(rows) {
+		return
+	}
+	depth := rows[m.Cursor].depth
+	for i := m.Cursor + delta; i >= 0 && i < len(rows); i += delta {
+		if rows[i].depth < depth {
+			return // 离开了父文件夹
+		}
+		if rows[i].depth == depth {
+			m.setCursor(i)
+			return
+		}
+	}
+}
+
+// setCollapsedRecursive 递归展开或折叠光标所在的文件夹 (文件则为其所在文件夹) 的整个子树
+// wholeTree 为 true 时作用于整棵树
+func (m MainModel) setCollapsedRecursive(collapsed, wholeTree bool) (MainModel, tea.Cmd) {
+	rows := m.visibleRows()
+	if m.Cursor >= len(rows) {
+		return m, nil
+	}
+
+	// 光标所在的节点及其祖先，由近到远
+	path := []*model.Node{rows[m.Cursor].node}
+	for i, depth := m.Cursor-1, rows[m.Cursor].depth; i >= 0 && depth > 0; i-- {
+		if rows[i].depth < depth {
+			path = append(path, rows[i].node)
+			depth = rows[i].depth
+		}
+	}
+
+	// 光标在文件上时作用于其父文件夹 (顶层文件则为整棵树)
+	target := m.RootNode
+	for _, node := range path {
+		if node.IsDir && !wholeTree {
+			target = node
+			break
+		}
+	}
+
+	before := m.snapshot()
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		if node.IsDir && node != m.RootNode {
+			node.Collapsed = collapsed
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(target)
+
+	name := target.Name
+	if target == m.RootNode {
+		name = "project root"
+	}
+	if collapsed {
+		m.recordState("Collapse all under "+name, before)
+		m.StatusMsg = "Collapsed all under " + name
+		// 光标所在的行可能被折叠进去了，移到仍然可见的最近的祖先上
+		for _, node := range path {
+			idx := 0
+			if m.findNodeIndex(m.RootNode.Children, node, &idx) {
+				m.setCursor(idx)
+				break
+			}
+		}
+	} else {
+		m.recordState("Expand all under "+name, before)
+		m.StatusMsg = "Expanded all under " + name
+	}
+	m.clampCursor()
+	load := m.loadExpanded()
+	return m, tea.Batch(m.triggerDebouncedSave(), load)
+}
+
+// openGotoInput 打开 "跳转到路径" 输入框
+func (m MainModel) openGotoInput() (tea.Model, tea.Cmd) {
+	m.GotoMode = true
+	m.GotoInput.SetValue("")
+	m.GotoInput.Focus()
+	return m, textinput.Blink
+}
+
+// updateGotoMode 处理跳转路径输入框的按键
+func (m MainModel) updateGotoMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			m.GotoMode = false
+			return m.gotoPath(strings.TrimSpace(m.GotoInput.Value()))
+
+		case "esc":
+			m.GotoMode = false
+			m.StatusMsg = "Cancelled."
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.GotoInput, cmd = m.GotoInput.Update(msg)
+	return m, cmd
+}
+
+// gotoPath 展开目标节点的所有祖先并把光标移到它上面
+// 先按相对路径精确查找，找不到时使用模糊匹配得分最高的节点
+func (m MainModel) gotoPath(input string) (tea.Model, tea.Cmd) {
+	input = strings.Trim(filepath.ToSlash(input), "/")
+	if input == "" {
+		return m, nil
+	}
+	target := m.findRel(strings.TrimPrefix(input, "./"))
+	if target == nil {
+		target = m.bestFuzzyNode(input)
+	}
+	if target == nil {
+		m.StatusMsg = "No such path: " + input
+		return m, nil
+	}
+
+	// 展开祖先 (记录为可撤销的操作)
+	before := m.snapshot()
+	var expand func(node *model.Node) bool
+	expand = func(node *model.Node) bool {
+		for _, child := range node.Children {
+			if child == target || (child.IsDir && expand(child)) {
+				if node != m.RootNode {
+					node.Collapsed = false
+				}
+				return true
+			}
+		}
+		return false
+	}
+	expand(m.RootNode)
+	m.recordState("Go to "+target.Name, before)
+
+	// 当前的过滤条件隐藏了目标时，清除过滤
+	if idx := 0; !m.findNodeIndex(m.RootNode.Children, target, &idx) {
+		m.SearchInput.SetValue("")
+		m.GitMode = false
+		if m.Grep != nil {
+			m = m.clearGrep()
+		}
+	}
+	m.jumpTo(target)
+	m.StatusMsg = "" // 状态栏显示目标的路径
+	return m, m.triggerDebouncedSave()
+}
+
+// bestFuzzyNode 返回相对路径与输入模糊匹配得分最高的节点 (得分相同时路径短的优先)
+func (m MainModel) bestFuzzyNode(pattern string) *model.Node {
+	var best *model.Node
+	bestScore, bestLen := 0, 0
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		for _, child := range node.Children {
+			relPath := core.NodeRelPath(m.RootNode, child)
+			if result, ok := core.FuzzyMatch(pattern, relPath); ok {
+				if best == nil || result.Score > bestScore || (result.Score == bestScore && len(relPath) < bestLen) {
+					best, bestScore, bestLen = child, result.Score, len(relPath)
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(m.RootNode)
+	return best
+}
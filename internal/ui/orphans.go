@@ -0,0 +1,171 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// orphanSummary 生成启动时的提示：自动重新关联了多少注释，还有多少失效
+func orphanSummary(config *core.ConfigState) string {
+	if config == nil {
+		return ""
+	}
+
+	var parts []string
+	if config.Reattached > 0 {
+		parts = append(parts, fmt.Sprintf("Re-attached %d moved entries", config.Reattached))
+	}
+	if len(config.Orphans) > 0 {
+		parts = append(parts, fmt.Sprintf("%d orphaned entries, press [O] to review", len(config.Orphans)))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// orphans 返回当前的失效条目列表
+func (m MainModel) orphans() []core.Orphan {
+	if m.Config == nil {
+		return nil
+	}
+	return m.Config.Orphans
+}
+
+// openOrphanPanel 打开失效注释面板
+func (m MainModel) openOrphanPanel() (tea.Model, tea.Cmd) {
+	if len(m.orphans()) == 0 {
+		m.StatusMsg = "No orphaned entries in " + core.ConfigFileName
+		return m, nil
+	}
+	m.OrphanMode = true
+	m.OrphanCursor = 0
+	m.StatusMsg = ""
+	return m, nil
+}
+
+// updateOrphanMode 处理失效注释面板的按键
+func (m MainModel) updateOrphanMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	orphans := m.orphans()
+	switch {
+	case key.Matches(keyMsg, m.Keys.Clear, m.Keys.Orphans, m.Keys.Quit):
+		m.OrphanMode = false
+		m.StatusMsg = ""
+
+	case key.Matches(keyMsg, m.Keys.Up):
+		if m.OrphanCursor > 0 {
+			m.OrphanCursor--
+		}
+
+	case key.Matches(keyMsg, m.Keys.Down):
+		if m.OrphanCursor < len(orphans)-1 {
+			m.OrphanCursor++
+		}
+
+	// 输入新路径，重新关联
+	case keyMsg.String() == "enter" || key.Matches(keyMsg, m.Keys.Rename):
+		if len(orphans) == 0 {
+			return m, nil
+		}
+		m.RemapMode = true
+		m.RemapInput.SetValue(orphans[m.OrphanCursor].Path)
+		m.RemapInput.CursorEnd()
+		m.RemapInput.Focus()
+		return m, textinput.Blink
+
+	// 丢弃条目
+	case key.Matches(keyMsg, m.Keys.Delete):
+		if len(orphans) == 0 {
+			return m, nil
+		}
+		orphan := orphans[m.OrphanCursor]
+		m.StatusMsg = "Discarded " + orphan.Path
+		m.removeOrphan(m.OrphanCursor)
+		m.recordChanges("Discard "+orphan.Path, core.OrphanChange(orphan))
+		return m, m.triggerDebouncedSave()
+	}
+	return m, nil
+}
+
+// updateRemapMode 处理重新关联路径输入框的按键
+func (m MainModel) updateRemapMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			m.RemapMode = false
+			orphan := m.orphans()[m.OrphanCursor]
+			target := strings.TrimSpace(m.RemapInput.Value())
+			before := m.snapshot()
+			if err := core.AttachOrphan(m.RootPath, m.RootNode, orphan, target); err != nil {
+				m.StatusMsg = "Error: " + err.Error()
+				return m, nil
+			}
+			m.StatusMsg = fmt.Sprintf("Re-attached %s -> %s", orphan.Path, target)
+			m.removeOrphan(m.OrphanCursor)
+			// 节点状态的变化与失效条目一起撤销
+			changes := append(core.DiffState(before, m.snapshot()), core.OrphanChange(orphan))
+			m.recordChanges("Re-attach "+orphan.Path, changes...)
+			m.invalidateSearch()
+			return m, m.triggerDebouncedSave()
+
+		case "esc":
+			m.RemapMode = false
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.RemapInput, cmd = m.RemapInput.Update(msg)
+	return m, cmd
+}
+
+// removeOrphan 移除第 i 个失效条目，列表清空时关闭面板
+func (m *MainModel) removeOrphan(i int) {
+	orphans := m.Config.Orphans
+	m.Config.Orphans = append(orphans[:i:i], orphans[i+1:]...)
+
+	if m.OrphanCursor >= len(m.Config.Orphans) {
+		m.OrphanCursor = len(m.Config.Orphans) - 1
+	}
+	if len(m.Config.Orphans) == 0 {
+		m.OrphanCursor = 0
+		m.OrphanMode = false
+	}
+}
+
+// renderOrphans 渲染失效注释列表，替代文件树区域
+func (m MainModel) renderOrphans() []string {
+	lines := []string{dimmedStyle.Render(fmt.Sprintf("Orphaned entries in %s (path no longer exists):", core.ConfigFileName))}
+	for i, orphan := range m.orphans() {
+		cursorIndicator := "  "
+		style := normalStyle
+		if i == m.OrphanCursor {
+			cursorIndicator = "> "
+			style = selectedStyle
+		}
+
+		// 没有注释的条目显示其保存的其它状态
+		detail := orphan.Config.Annotation
+		if detail == "" {
+			var flags []string
+			if orphan.Config.Hidden {
+				flags = append(flags, "hidden")
+			}
+			if orphan.Config.Collapsed {
+				flags = append(flags, "collapsed")
+			}
+			detail = "(" + strings.Join(flags, ", ") + ")"
+		}
+
+		line := cursorIndicator + style.Render(orphan.Path) + annotationStyle.Render("  # "+detail)
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// paletteCommand 是命令面板中的一条命令
+type paletteCommand struct {
+	name string      // 显示名称，同时用于模糊匹配
+	key  key.Binding // 对应的按键 (仅用于提示，可以未绑定)
+	run  func(m MainModel) (tea.Model, tea.Cmd)
+}
+
+// paletteMatch 是一条命中的命令及其匹配位置
+type paletteMatch struct {
+	command paletteCommand
+	result  core.FuzzyResult
+}
+
+// sortModeNames 是排序方式在命令面板与状态栏中的名称
+var sortModeNames = map[string]string{
+	core.SortName:    "name",
+	core.SortFolders: "folders first",
+	core.SortExt:     "extension",
+	core.SortChanges: "changed lines",
+}
+
+// paletteCommands 返回命令面板中的所有命令，包括用户配置与 .gentr.json 中定义的自定义命令
+func (m MainModel) paletteCommands() []paletteCommand {
+	k, none := m.Keys, key.Binding{}
+	commands := []paletteCommand{
+		{"Copy tree to clipboard (Markdown)", k.CopyTree, MainModel.copyTree},
+		{"Export text file (gentr_output.txt)", k.SaveText, MainModel.saveText},
+		{"Export SVG images (dark & light)", k.SaveSVG, MainModel.saveSVG},
+		{"Export JSON file (gentr_output.json)", k.SaveJSON, MainModel.saveJSON},
+		{"Toggle git change filter", k.GitFilter, func(m MainModel) (tea.Model, tea.Cmd) { return m.toggleGitMode(), nil }},
+		{"Toggle preview pane", k.Preview, func(m MainModel) (tea.Model, tea.Cmd) { return m.togglePreview(), nil }},
+	}
+
+	for _, mode := range core.SortModes {
+		commands = append(commands, paletteCommand{"Sort by " + sortModeNames[mode], none, func(m MainModel) (tea.Model, tea.Cmd) {
+			return m.setSortMode(mode), nil
+		}})
+	}
+
+	commands = append(commands, []paletteCommand{
+		{"Expand all folders", none, func(m MainModel) (tea.Model, tea.Cmd) { return m.setCollapsedRecursive(false, true) }},
+		{"Collapse all folders", none, func(m MainModel) (tea.Model, tea.Cmd) { return m.setCollapsedRecursive(true, true) }},
+		{"Search file names", k.Search, MainModel.openSearch},
+		{"Search file contents", k.Grep, MainModel.openGrepInput},
+		{"Go to path", k.GoTo, MainModel.openGotoInput},
+		{"Compare against git ref", k.DiffRef, MainModel.openRefInput},
+		{"Reload git status", none, func(m MainModel) (tea.Model, tea.Cmd) { return m, m.refreshGitCmd("Git status reloaded") }},
+		{"Reload tree from disk", none, MainModel.reload},
+		{"Review orphaned annotations", k.Orphans, MainModel.openOrphanPanel},
+		{"Open in editor", k.Edit, MainModel.openInEditor},
+		{"Open shell here", k.Shell, MainModel.openShell},
+		{"New file or folder", k.Create, func(m MainModel) (tea.Model, tea.Cmd) { return m.openFileOp(fileOpCreate) }},
+		{"Select all matching", k.SelectMatching, func(m MainModel) (tea.Model, tea.Cmd) { return m.selectMatching(), nil }},
+		{"Copy selected paths", k.CopyPaths, func(m MainModel) (tea.Model, tea.Cmd) { return m.copySelectedPaths(), nil }},
+		{"Clear selection", k.Clear, func(m MainModel) (tea.Model, tea.Cmd) { return m.clearSelection(), nil }},
+		{"Undo", k.Undo, func(m MainModel) (tea.Model, tea.Cmd) { return m.undo(false) }},
+		{"Redo", k.Redo, func(m MainModel) (tea.Model, tea.Cmd) { return m.undo(true) }},
+		{"Show key bindings", k.Help, MainModel.openHelp},
+		{"Quit", k.Quit, MainModel.quit},
+	}...)
+
+	// 自定义命令 (没有设置按键的命令只能从命令面板运行)
+	for _, c := range m.Settings.Commands {
+		if c.Run == "" {
+			continue
+		}
+		binding := none
+		if c.Key != "" {
+			binding = newBinding(c.Title(), c.Key)
+		}
+		commands = append(commands, paletteCommand{"Run: " + c.Title(), binding, func(m MainModel) (tea.Model, tea.Cmd) {
+			return m.runCommand(c)
+		}})
+	}
+	return commands
+}
+
+// paletteMatches 返回与输入模糊匹配的命令，按得分从高到低排列 (输入为空时保持原顺序)
+func (m MainModel) paletteMatches() []paletteMatch {
+	query := strings.TrimSpace(m.PaletteInput.Value())
+	var matches []paletteMatch
+	for _, c := range m.paletteCommands() {
+		if result, ok := core.FuzzyMatch(query, c.name); ok {
+			matches = append(matches, paletteMatch{command: c, result: result})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].result.Score > matches[j].result.Score
+	})
+	return matches
+}
+
+// openPalette 打开命令面板
+func (m MainModel) openPalette() (tea.Model, tea.Cmd) {
+	m.PaletteMode = true
+	m.PaletteCursor = 0
+	m.PaletteInput.SetValue("")
+	m.PaletteInput.Focus()
+	m.StatusMsg = ""
+	return m, textinput.Blink
+}
+
+// updatePaletteMode 处理命令面板的按键
+func (m MainModel) updatePaletteMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "up", "ctrl+p":
+			if m.PaletteCursor > 0 {
+				m.PaletteCursor--
+			}
+			return m, nil
+
+		case "down", "ctrl+n":
+			if m.PaletteCursor < len(m.paletteMatches())-1 {
+				m.PaletteCursor++
+			}
+			return m, nil
+
+		case "enter":
+			matches := m.paletteMatches()
+			m.PaletteMode = false
+			if m.PaletteCursor >= len(matches) {
+				return m, nil
+			}
+			return matches[m.PaletteCursor].command.run(m)
+
+		case "esc":
+			m.PaletteMode = false
+			return m, nil
+		}
+	}
+
+	before := m.PaletteInput.Value()
+	var cmd tea.Cmd
+	m.PaletteInput, cmd = m.PaletteInput.Update(msg)
+	if m.PaletteInput.Value() != before {
+		m.PaletteCursor = 0
+	}
+	return m, cmd
+}
+
+// renderPalette 渲染命中的命令列表，替代文件树区域 (第一行是标题)
+func (m MainModel) renderPalette() []string {
+	matches := m.paletteMatches()
+	lines := []string{dimmedStyle.Render("Commands:")}
+	if len(matches) == 0 {
+		return append(lines, dimmedStyle.Render("  No matching commands"))
+	}
+
+	// 按键提示右对齐到最长的名称之后
+	nameWidth := 0
+	for _, match := range matches {
+		nameWidth = max(nameWidth, lipgloss.Width(match.command.name))
+	}
+
+	for i, match := range matches {
+		cursorIndicator := "  "
+		style, matchStyle := normalStyle, searchMatchStyle
+		if i == m.PaletteCursor {
+			cursorIndicator = "> "
+			style, matchStyle = selectedStyle, selectedStyle.Underline(true)
+		}
+		name := match.command.name
+		line := cursorIndicator + renderHighlighted(name, match.result.Positions, 0, false, style, matchStyle)
+		if match.command.key.Enabled() {
+			line += strings.Repeat(" ", nameWidth-lipgloss.Width(name)+2) + dimmedStyle.Render("["+match.command.key.Help().Key+"]")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// setSortMode 切换子节点的排序方式，光标停留在原来的节点上
+func (m MainModel) setSortMode(mode string) MainModel {
+	m.SortMode = mode
+	idx := 0
+	current := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	core.SortTree(m.RootNode, mode)
+	m.invalidateSearch()
+	if current != nil {
+		m.jumpTo(current)
+	}
+	m.StatusMsg = "Sort: " + sortModeNames[mode]
+	return m
+}
+
+// resort 在树的结构变化后 (新插入的节点总是按文件名排列) 重新应用排序方式
+func (m *MainModel) resort() {
+	if m.SortMode == "" || m.SortMode == core.SortName {
+		return
+	}
+	core.SortTree(m.RootNode, m.SortMode)
+}
+
+// paletteHint 返回命令面板输入框下方的提示
+func (m MainModel) paletteHint() string {
+	return fmt.Sprintf("(%d commands | ↑/↓ select, Enter to run, Esc to cancel)", len(m.paletteMatches()))
+}
@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// previewMinWidth 终端宽度小于该值时不分栏显示预览
+	previewMinWidth = 80
+	// previewMaxBytes 预览最多读取的字节数
+	previewMaxBytes = 64 * 1024
+)
+
+// previewTitleStyle 预览区标题：加粗
+var previewTitleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Bold(true)
+
+// previewCache 缓存光标所在节点的预览内容，避免每次渲染都读取磁盘
+// 以指针形式保存在 MainModel 中，值拷贝之间共享同一份缓存
+type previewCache struct {
+	node    *model.Node
+	modTime time.Time
+	width   int // 读取时的终端尺寸，窗口大小变化后重新截断
+	height  int
+	info    string   // 标题下方的说明，例如 "1.2 KB" 或 "3 folders, 12 files"
+	lines   []string // 文件的前几行 (已着色)
+	message string   // 无法显示内容时的提示，例如二进制文件
+}
+
+// previewVisible 判断当前是否分栏显示预览
+func (m MainModel) previewVisible() bool {
+	if !m.PreviewMode || m.Width < previewMinWidth {
+		return false
+	}
+	// 结果列表、失效注释面板、帮助与命令面板占满整个宽度
+	return !(m.SearchMode && m.RankedMode) && !m.OrphanMode && !m.RemapMode && !m.HelpMode && !m.PaletteMode
+}
+
+// treeWidth 返回分栏时左侧文件树的宽度
+func (m MainModel) treeWidth() int {
+	return m.Width * 45 / 100
+}
+
+// togglePreview 切换预览分栏
+func (m MainModel) togglePreview() MainModel {
+	m.PreviewMode = !m.PreviewMode
+	switch {
+	case !m.PreviewMode:
+		m.StatusMsg = "Preview: OFF"
+	case m.Width < previewMinWidth:
+		m.StatusMsg = fmt.Sprintf("Preview: ON (terminal narrower than %d columns, hidden)", previewMinWidth)
+	default:
+		m.StatusMsg = "Preview: ON"
+	}
+	return m
+}
+
+// joinPreview 把文件树的可见行与右侧预览拼接为分栏布局
+func (m MainModel) joinPreview(treeLines []string, height int) string {
+	treeWidth := m.treeWidth()
+	paneWidth := m.Width - treeWidth - 3 // 分隔线 " │ "
+	pane := m.renderPreview(paneWidth, height)
+	separator := dimmedStyle.Render(" │ ")
+
+	rows := make([]string, height)
+	for i := 0; i < height; i++ {
+		left := ""
+		if i < len(treeLines) {
+			left = treeLines[i]
+		}
+		// 左侧补齐到固定宽度，保证分隔线对齐
+		if pad := treeWidth - lipgloss.Width(left); pad > 0 {
+			left += strings.Repeat(" ", pad)
+		}
+		right := ""
+		if i < len(pane) {
+			right = pane[i]
+		}
+		rows[i] = left + separator + right
+	}
+	return strings.Join(rows, "\n")
+}
+
+// renderPreview 渲染光标所在节点的预览，返回最多 height 行
+func (m MainModel) renderPreview(width, height int) []string {
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	if node == nil {
+		return []string{dimmedStyle.Render("(No selection)")}
+	}
+
+	cache := m.loadPreview(node)
+	lines := []string{
+		previewTitleStyle.Render(truncateWidth(node.Name, width)),
+		dimmedStyle.Render(truncateWidth(cache.info, width)),
+	}
+	if node.Annotation != "" {
+		lines = append(lines, annotationStyle.Render(truncateWidth("# "+node.Annotation, width)))
+	}
+	lines = append(lines, dimmedStyle.Render(strings.Repeat("─", width)))
+
+	if cache.message != "" {
+		lines = append(lines, dimmedStyle.Render(cache.message))
+	}
+	lines = append(lines, cache.lines...)
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// loadPreview 返回节点的预览内容，节点、文件修改时间或窗口大小变化时重新读取
+func (m MainModel) loadPreview(node *model.Node) *previewCache {
+	var modTime time.Time
+	if info, err := os.Stat(node.Path); err == nil {
+		modTime = info.ModTime()
+	}
+	if c := m.preview; c != nil && c.node == node && c.modTime.Equal(modTime) && c.width == m.Width && c.height == m.Height {
+		return c
+	}
+
+	cache := &previewCache{node: node, modTime: modTime, width: m.Width, height: m.Height}
+	if node.IsDir {
+		cache.info = summarizeDir(node)
+	} else {
+		m.readFilePreview(node, cache)
+	}
+
+	if m.preview != nil {
+		*m.preview = *cache
+	}
+	return cache
+}
+
+// readFilePreview 读取文件开头的若干行并着色
+func (m MainModel) readFilePreview(node *model.Node, cache *previewCache) {
+	if node.Ghost {
+		cache.info = "deleted"
+		cache.message = "(file no longer exists on disk)"
+		return
+	}
+
+	f, err := os.Open(node.Path)
+	if err != nil {
+		cache.message = "(" + err.Error() + ")"
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		cache.message = "(" + err.Error() + ")"
+		return
+	}
+	cache.info = formatSize(info.Size())
+
+	reader := bufio.NewReaderSize(f, previewMaxBytes)
+	if core.IsBinary(reader) {
+		cache.message = "(binary file)"
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, previewMaxBytes))
+	if err != nil {
+		cache.message = "(" + err.Error() + ")"
+		return
+	}
+
+	// 只需要填满视口的行数
+	rawLines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if limit := m.viewportHeight(); len(rawLines) > limit {
+		rawLines = rawLines[:limit]
+	}
+
+	syn := syntaxFor(node.Name)
+	width := m.Width - m.treeWidth() - 3
+	numberWidth := len(fmt.Sprint(len(rawLines)))
+	for i, line := range rawLines {
+		number := fmt.Sprintf("%*d ", numberWidth, i+1)
+		text := strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    ")
+		text = truncateWidth(text, width-len(number))
+		cache.lines = append(cache.lines, dimmedStyle.Render(number)+syn.highlightLine(text))
+	}
+}
+
+// summarizeDir 统计文件夹的直接子项数量、全部文件数与总大小
+func summarizeDir(node *model.Node) string {
+	dirs, files := 0, 0
+	for _, child := range node.Children {
+		if child.Ghost {
+			continue
+		}
+		if child.IsDir {
+			dirs++
+		} else {
+			files++
+		}
+	}
+
+	totalFiles, totalSize := 0, int64(0)
+	var walk func(n *model.Node)
+	walk = func(n *model.Node) {
+		for _, child := range n.Children {
+			if child.Ghost {
+				continue
+			}
+			if child.IsDir {
+				walk(child)
+				continue
+			}
+			totalFiles++
+			if info, err := os.Lstat(child.Path); err == nil {
+				totalSize += info.Size()
+			}
+		}
+	}
+	walk(node)
+
+	return fmt.Sprintf("%d folders, %d files | %d files in total, %s", dirs, files, totalFiles, formatSize(totalSize))
+}
+
+// formatSize 把字节数格式化为易读的形式，例如 "1.2 KB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// renderTree 用给定的终端宽度渲染整棵树，返回每一行
+func renderTree(m MainModel, width int) []string {
+	m.Width = width
+	idx := 0
+	return strings.Split(strings.TrimSuffix(m.renderChildren(m.RootNode.Children, "", &idx, false), "\n"), "\n")
+}
+
+// checkRowWidths 检查每种宽度下渲染的每一行都没有超出终端宽度，也没有 NUL 字符
+// 前缀 (光标 + 连接符 + 图标) 固定占 8 个宽度，从能放下前缀的宽度开始检查
+func checkRowWidths(t *testing.T, m MainModel) {
+	t.Helper()
+	for width := 10; width <= 160; width++ {
+		for i, line := range renderTree(m, width) {
+			if strings.ContainsRune(line, 0) {
+				t.Fatalf("width %d, line %d contains NUL: %q", width, i, line)
+			}
+			if w := lipgloss.Width(line); w > width {
+				t.Errorf("width %d, line %d is %d wide: %q", width, i, w, line)
+			}
+		}
+	}
+}
+
+func TestRenderRenameRowWiderThanTerminal(t *testing.T) {
+	renamed := &model.Node{
+		Name:        "a.go",
+		GitStatus:   model.GitRenamed,
+		GitIndex:    "R",
+		GitOrigPath: "very/long/original/location/of/the/file/that/was/renamed/in/this/change/a.go",
+		Annotation:  "Entry point",
+	}
+	long := &model.Node{Name: strings.Repeat("長い名前", 10) + ".txt"}
+	root := &model.Node{Name: "root", IsDir: true, Children: []*model.Node{renamed, long}}
+	m := InitialModel("/project", root, core.LimitInfo{}, "test")
+
+	checkRowWidths(t, m)
+
+	// 空间足够放下文件名时只截断来源路径，不截断文件名
+	lines := renderTree(m, 40)
+	if !strings.Contains(lines[0], "a.go [R] ← very") || !strings.Contains(lines[0], "…") {
+		t.Errorf("rename row at width 40 = %q, want the name and a shortened origin", lines[0])
+	}
+
+	// 来源路径放不下时整段丢弃，文件名保持完整
+	lines = renderTree(m, 20)
+	if !strings.Contains(lines[0], "a.go [R]") || strings.Contains(lines[0], "←") {
+		t.Errorf("rename row at width 20 = %q, want the name without the origin", lines[0])
+	}
+
+	// 文件名本身放不下时按显示宽度截断
+	lines = renderTree(m, 30)
+	if want := "長い名前長い名前長い…"; !strings.Contains(lines[1], want) {
+		t.Errorf("long row at width 30 = %q, want %q", lines[1], want)
+	}
+}
+
+func TestRenderBadgesInNarrowRows(t *testing.T) {
+	modified := &model.Node{
+		Name:         "server.go",
+		GitStatus:    model.GitModified,
+		GitWorktree:  "M",
+		LinesAdded:   120,
+		LinesRemoved: 30,
+	}
+	loading := &model.Node{Name: "node_modules", IsDir: true, Collapsed: true}
+	root := &model.Node{Name: "root", IsDir: true, Children: []*model.Node{modified, loading}}
+	m := InitialModel("/project", root, core.LimitInfo{}, "test")
+	m.loading = map[*model.Node]bool{loading: true}
+	m.Grep = &grepState{counts: map[*model.Node]int{modified: 12, loading: 3}}
+
+	checkRowWidths(t, m)
+	m.GitMode = true
+	checkRowWidths(t, m)
+	m.GitMode = false
+
+	// 66 列终端开启预览时左侧只有 29 列：徽标放不下时被丢弃，文件夹名保持完整
+	m.Width = 66
+	lines := renderTree(m, m.treeWidth())
+	if !strings.Contains(lines[1], "node_modules (3)") || strings.Contains(lines[1], "loading") {
+		t.Errorf("loading row in the preview split = %q, want the name and match count only", lines[1])
+	}
+
+	// 更窄时只截断文件夹名
+	lines = renderTree(m, 16)
+	if !strings.Contains(lines[1], "node_m…") {
+		t.Errorf("loading row at width 16 = %q, want a truncated name", lines[1])
+	}
+}
@@ -0,0 +1,211 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 扫描进度消息
+type scanProgressMsg core.Progress
+
+// 扫描完成消息
+type scanDoneMsg struct {
+	root   *model.Node
+	limits core.LimitInfo
+	config *core.ConfigState
+	err    error
+}
+
+// ScanModel 在后台扫描目录时显示实时进度，扫描完成后切换为 MainModel
+type ScanModel struct {
+	RootPath       string
+	Opts           core.WalkOptions
+	CurrentVersion string
+	Keys           KeyMap                // 按键绑定，传递给 MainModel
+	Settings       core.ResolvedSettings // 生效设置，传递给 MainModel
+
+	Progress core.Progress
+	Spinner  spinner.Model
+
+	// 扫描失败时的错误，Ctrl+C 取消时为 context.Canceled
+	Err error
+
+	// 终端尺寸，切换到 MainModel 时一并传递
+	Width  int
+	Height int
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	progressCh chan core.Progress
+	doneCh     chan scanDoneMsg
+}
+
+// NewScanModel 创建扫描界面
+func NewScanModel(rootPath string, opts core.WalkOptions, currentVersion string) ScanModel {
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	sp.Style = selectedStyle
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return ScanModel{
+		RootPath:       rootPath,
+		Opts:           opts,
+		CurrentVersion: currentVersion,
+		Keys:           DefaultKeyMap(),
+		Settings:       core.ResolveSettings(core.Settings{}, core.Settings{}),
+		Spinner:        sp,
+		Width:          80,
+		Height:         24,
+		ctx:            ctx,
+		cancel:         cancel,
+		progressCh:     make(chan core.Progress, 1),
+		doneCh:         make(chan scanDoneMsg, 1),
+	}
+}
+
+// Init 启动后台扫描协程
+func (m ScanModel) Init() tea.Cmd {
+	opts := m.Opts
+	opts.OnProgress = func(p core.Progress) {
+		// 进度只保留最新值，UI 来不及消费时直接丢弃旧值，不阻塞扫描
+		select {
+		case <-m.progressCh:
+		default:
+		}
+		m.progressCh <- p
+	}
+
+	go func() {
+		root, limits, err := core.Walk(m.ctx, m.RootPath, opts)
+		var config *core.ConfigState
+		if err == nil {
+			// 如果有则加载持久化配置
+			// 会修改 rootNode 里的 Annotation/Hidden/Collapsed 状态
+			config = core.LoadConfig(m.RootPath, root)
+		}
+		m.doneCh <- scanDoneMsg{root: root, limits: limits, config: config, err: err}
+	}()
+
+	return tea.Batch(m.Spinner.Tick, m.waitForProgress, m.waitForDone)
+}
+
+// waitForProgress 等待下一条进度消息
+func (m ScanModel) waitForProgress() tea.Msg {
+	select {
+	case p := <-m.progressCh:
+		return scanProgressMsg(p)
+	case <-m.ctx.Done():
+		return nil
+	}
+}
+
+// waitForDone 等待扫描结束
+func (m ScanModel) waitForDone() tea.Msg {
+	return <-m.doneCh
+}
+
+// Update 处理进度、完成与取消
+func (m ScanModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Width = msg.Width
+		m.Height = msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.cancel()
+			m.Err = context.Canceled
+			return m, tea.Quit
+		}
+
+	case scanProgressMsg:
+		m.Progress = core.Progress(msg)
+		return m, m.waitForProgress
+
+	case scanDoneMsg:
+		// 无论成功与否都释放 context，停止进度监听
+		m.cancel()
+		if msg.err != nil {
+			m.Err = msg.err
+			return m, tea.Quit
+		}
+
+		// 扫描完成，切换到主界面，并继承终端尺寸
+		mainModel := InitialModel(m.RootPath, msg.root, msg.limits, m.CurrentVersion)
+		mainModel.Width = m.Width
+		mainModel.Height = m.Height
+		mainModel.Keys = m.Keys
+		mainModel.Settings = m.Settings
+
+		// 保存扫描选项，对比模式下默认打开 Git 过滤
+		mainModel.WalkOpts = m.Opts
+		if m.Opts.Lazy {
+			mainModel.dirLoader = core.NewDirLoader(m.RootPath, m.Opts)
+		}
+		mainModel.DiffRef = m.Opts.DiffRef
+		mainModel.GitMode = m.Opts.DiffRef != ""
+
+		// 提示路径失效的注释的处理结果
+		mainModel.Config = msg.config
+		mainModel.StatusMsg = orphanSummary(msg.config)
+		return mainModel, mainModel.Init()
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.Spinner, cmd = m.Spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// View 渲染扫描进度
+func (m ScanModel) View() string {
+	if m.Err != nil {
+		return ""
+	}
+
+	result := fmt.Sprintf("\n %s Scanning… %d files (%d folders)\n\n %s\n\n %s",
+		m.Spinner.View(),
+		m.Progress.Files,
+		m.Progress.Dirs,
+		dimmedStyle.Render(m.RootPath),
+		dimmedStyle.Render("[Ctrl+C] Cancel"),
+	)
+
+	// 补齐空行，消除终端伪影
+	lines := strings.Count(result, "\n") + 1
+	if lines < m.Height {
+		result += strings.Repeat("\n", m.Height-lines)
+	}
+	return result
+}
+
+// reload 保存当前状态后重新扫描整个目录 (例如在外部新增了大量文件)
+func (m MainModel) reload() (tea.Model, tea.Cmd) {
+	m.saveStateImmediate()
+	if m.SaveErr != nil {
+		m.StatusMsg = "Error saving " + core.ConfigFileName + ": " + m.SaveErr.Error()
+		return m, nil
+	}
+
+	scan := NewScanModel(m.RootPath, m.WalkOpts, m.CurrentVersion)
+	scan.Width = m.Width
+	scan.Height = m.Height
+	scan.Keys = m.Keys
+	scan.Settings = m.Settings
+	return scan, scan.Init()
+}
+
+// Cancelled 判断扫描是否被用户取消
+func (m ScanModel) Cancelled() bool {
+	return errors.Is(m.Err, context.Canceled)
+}
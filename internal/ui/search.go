@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchMatch 是一个节点的模糊匹配结果
+type searchMatch struct {
+	node    *model.Node
+	relPath string
+	result  core.FuzzyResult
+}
+
+// searchState 缓存当前搜索词的匹配结果，避免每次渲染都重新匹配整棵树
+// 以指针形式保存在 MainModel 中，值拷贝之间共享同一份缓存
+type searchState struct {
+	valid    bool
+	term     string
+	err      error                        // 查询语法错误，此时不做过滤
+	matches  map[*model.Node]*searchMatch // 自身匹配的节点
+	visible  map[*model.Node]bool         // 自身或子孙匹配的节点
+	ranked   []*searchMatch               // 按得分从高到低排序
+	unloaded int                          // 懒加载模式下尚未读取、没有参与匹配的文件夹数
+}
+
+// invalidateSearch 在树的结构变化后 (例如幽灵节点增减) 丢弃缓存
+func (m MainModel) invalidateSearch() {
+	if m.search != nil {
+		m.search.valid = false
+	}
+}
+
+// searchResults 返回当前搜索词的匹配结果，搜索词变化时重新计算
+func (m MainModel) searchResults() *searchState {
+	term := m.SearchInput.Value()
+	if m.search == nil {
+		return computeSearch(m.RootPath, m.RootNode, term)
+	}
+	if !m.search.valid || m.search.term != term {
+		*m.search = *computeSearch(m.RootPath, m.RootNode, term)
+	}
+	return m.search
+}
+
+// computeSearch 解析查询语句 (见 core.ParseQuery)，检查每个节点相对根目录的完整路径
+func computeSearch(rootPath string, root *model.Node, term string) *searchState {
+	s := &searchState{
+		valid:   true,
+		term:    term,
+		matches: make(map[*model.Node]*searchMatch),
+		visible: make(map[*model.Node]bool),
+	}
+	query, err := core.ParseQuery(term)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	if query == nil {
+		return s
+	}
+
+	// 返回值表示节点自身或其子孙是否匹配
+	var walk func(node *model.Node) bool
+	walk = func(node *model.Node) bool {
+		visible := false
+		if node.Unloaded {
+			s.unloaded++
+		}
+		relPath, err := filepath.Rel(rootPath, node.Path)
+		if err == nil {
+			relPath = filepath.ToSlash(relPath)
+			if query.Match(node, relPath) {
+				match := &searchMatch{node: node, relPath: relPath, result: query.Score(relPath)}
+				s.matches[node] = match
+				s.ranked = append(s.ranked, match)
+				visible = true
+			}
+		}
+		for _, child := range node.Children {
+			if walk(child) {
+				visible = true
+			}
+		}
+		if visible {
+			s.visible[node] = true
+		}
+		return visible
+	}
+	for _, child := range root.Children {
+		walk(child)
+	}
+
+	// 得分相同时路径短的优先，再按路径排序保证结果稳定
+	sort.SliceStable(s.ranked, func(i, j int) bool {
+		a, b := s.ranked[i], s.ranked[j]
+		if a.result.Score != b.result.Score {
+			return a.result.Score > b.result.Score
+		}
+		if len(a.relPath) != len(b.relPath) {
+			return len(a.relPath) < len(b.relPath)
+		}
+		return a.relPath < b.relPath
+	})
+	return s
+}
+
+// updateSearchMode 处理搜索输入框的按键
+// Tab 在目录树与按得分排序的扁平结果列表之间切换
+func (m MainModel) updateSearchMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "tab":
+			m.RankedMode = !m.RankedMode
+			m.RankCursor = 0
+			return m, nil
+
+		case "up", "ctrl+p":
+			if m.RankedMode {
+				if m.RankCursor > 0 {
+					m.RankCursor--
+				}
+				return m, nil
+			}
+
+		case "down", "ctrl+n":
+			if m.RankedMode {
+				if m.RankCursor < len(m.searchResults().ranked)-1 {
+					m.RankCursor++
+				}
+				return m, nil
+			}
+
+		case "enter", "esc":
+			// 退出搜索输入
+			// Esc 清空并退出，Enter 仅退出输入焦点但保留过滤结果
+			// 结果列表中按 Enter 会把光标跳转到选中的节点
+			var target *model.Node
+			if keyMsg.String() == "esc" {
+				m.SearchInput.SetValue("") // 清空搜索
+			} else if err := m.searchResults().err; err != nil {
+				// 查询有误时保留输入，方便修改
+				m.StatusMsg = "Invalid query: " + err.Error()
+			} else if ranked := m.searchResults().ranked; m.RankedMode && m.RankCursor < len(ranked) {
+				target = ranked[m.RankCursor].node
+			} else if hint := partialHint(m.searchResults().unloaded); hint != "" {
+				m.StatusMsg = "Search: " + hint
+			}
+			m.SearchMode = false
+			m.RankedMode = false
+			m.Cursor = 0 // 退出搜索时重置光标防止越界
+			m.ScrollOffset = 0
+			if target != nil {
+				m.jumpTo(target)
+			}
+			return m, nil
+		}
+	}
+
+	// 更新搜索输入框
+	before := m.SearchInput.Value()
+	var cmd tea.Cmd
+	m.SearchInput, cmd = m.SearchInput.Update(msg)
+
+	// 搜索词变了，树的结构就变了，光标必须重置，防止越界
+	if m.SearchInput.Value() != before {
+		m.Cursor = 0
+		m.ScrollOffset = 0
+		m.RankCursor = 0
+	}
+	return m, cmd
+}
+
+// jumpTo 把光标移动到指定节点 (节点必须在当前过滤条件下可见)
+func (m *MainModel) jumpTo(target *model.Node) {
+	idx := 0
+	if m.findNodeIndex(m.RootNode.Children, target, &idx) {
+		m.Cursor = idx
+		// 让目标行尽量位于视口中间
+		vpHeight := m.viewportHeight()
+		m.ScrollOffset = m.Cursor - vpHeight/2
+		if m.ScrollOffset < 0 {
+			m.ScrollOffset = 0
+		}
+	}
+}
+
+// findNodeIndex 计算节点在当前可见列表中的行号，与 getNodeAtCursor 的遍历规则一致
+func (m MainModel) findNodeIndex(children []*model.Node, target *model.Node, index *int) bool {
+	for _, child := range children {
+		if !m.shouldShow(child) {
+			continue
+		}
+		if child == target {
+			return true
+		}
+		*index++
+
+		shouldExpand := !child.Collapsed
+		if m.filtering() {
+			shouldExpand = true
+		}
+		if child.IsDir && shouldExpand && m.findNodeIndex(child.Children, target, index) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightName 渲染文件名，只高亮模糊匹配命中的字符
+// displayName 可能已被截断 (以 "…" 结尾)，截断部分不参与高亮
+func (m MainModel) highlightName(node *model.Node, displayName string, style, matchStyle lipgloss.Style) string {
+	if m.SearchInput.Value() == "" {
+		return style.Render(displayName)
+	}
+	match, ok := m.searchResults().matches[node]
+	if !ok {
+		return style.Render(displayName)
+	}
+
+	// 匹配位置是相对完整路径的，换算到文件名中的位置
+	offset := len([]rune(match.relPath)) - len([]rune(node.Name))
+	return renderHighlighted(displayName, match.result.Positions, offset, displayName != node.Name, style, matchStyle)
+}
+
+// renderHighlighted 按匹配位置分段渲染文本，相邻的同类字符合并为一段
+func renderHighlighted(text string, positions []int, offset int, truncated bool, style, matchStyle lipgloss.Style) string {
+	runes := []rune(text)
+	limit := len(runes)
+	if truncated {
+		limit-- // 不高亮结尾的 "…"
+	}
+
+	hit := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		if i := pos - offset; i >= 0 && i < limit {
+			hit[i] = true
+		}
+	}
+
+	var sb strings.Builder
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || hit[i] != hit[start] {
+			segment := string(runes[start:i])
+			if hit[start] {
+				sb.WriteString(matchStyle.Render(segment))
+			} else {
+				sb.WriteString(style.Render(segment))
+			}
+			start = i
+		}
+	}
+	return sb.String()
+}
+
+// renderRanked 渲染按得分排序的扁平结果列表，替代文件树区域
+func (m MainModel) renderRanked() []string {
+	ranked := m.searchResults().ranked
+	if len(ranked) == 0 {
+		return []string{dimmedStyle.Render("No matches")}
+	}
+
+	lines := make([]string, 0, len(ranked))
+	for i, match := range ranked {
+		cursorIndicator := "  "
+		style, matchStyle := normalStyle, searchMatchStyle
+		if i == m.RankCursor {
+			cursorIndicator = "> "
+			style, matchStyle = selectedStyle, selectedStyle.Underline(true)
+		}
+
+		path := match.relPath
+		if match.node.IsDir {
+			path += "/"
+		}
+
+		// 路径太长时从左侧截断，保留文件名
+		positions, offset := match.result.Positions, 0
+		if runes := []rune(path); m.Width > 5 && len(runes) > m.Width-3 {
+			cut := len(runes) - (m.Width - 3) + 1
+			path = "…" + string(runes[cut:])
+			offset = cut - 1
+			for len(positions) > 0 && positions[0] < cut {
+				positions = positions[1:]
+			}
+		}
+		lines = append(lines, cursorIndicator+renderHighlighted(path, positions, offset, false, style, matchStyle))
+	}
+	return lines
+}
@@ -0,0 +1,251 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DoraleCitrus/gentr/internal/core"
+	"github.com/DoraleCitrus/gentr/internal/model"
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 多选的行：蓝色加粗
+var markedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#81A1C1")).Bold(true)
+
+// visibleNodes 按显示顺序返回当前可见的节点
+func (m MainModel) visibleNodes() []*model.Node {
+	rows := m.visibleRows()
+	nodes := make([]*model.Node, len(rows))
+	for i, row := range rows {
+		nodes[i] = row.node
+	}
+	return nodes
+}
+
+// selectedNodes 按树的顺序返回所有选中的节点
+func (m MainModel) selectedNodes() []*model.Node {
+	if len(m.Selected) == 0 {
+		return nil
+	}
+	var nodes []*model.Node
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		for _, child := range node.Children {
+			if m.Selected[child] {
+				nodes = append(nodes, child)
+			}
+			walk(child)
+		}
+	}
+	walk(m.RootNode)
+	return nodes
+}
+
+// selectionRoots 返回选中的节点，去掉祖先也被选中的节点 (用于删除等对整个子树生效的操作)
+func (m MainModel) selectionRoots() []*model.Node {
+	var roots []*model.Node
+	var walk func(node *model.Node)
+	walk = func(node *model.Node) {
+		for _, child := range node.Children {
+			if m.Selected[child] {
+				roots = append(roots, child)
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(m.RootNode)
+	return roots
+}
+
+// toggleSelect 切换光标所在节点的选中状态，并把它记为范围选择的起点
+func (m MainModel) toggleSelect() MainModel {
+	idx := 0
+	node := m.getNodeAtCursor(m.RootNode.Children, &idx)
+	if node == nil {
+		return m
+	}
+	if m.Selected == nil {
+		m.Selected = make(map[*model.Node]bool)
+	}
+	if m.Selected[node] {
+		delete(m.Selected, node)
+	} else {
+		m.Selected[node] = true
+	}
+	m.selectAnchor = node
+	m.StatusMsg = ""
+	return m
+}
+
+// selectRange 选中从上一次切换的节点到光标之间的所有可见节点
+func (m MainModel) selectRange() MainModel {
+	nodes := m.visibleNodes()
+	anchor := -1
+	for i, node := range nodes {
+		if node == m.selectAnchor {
+			anchor = i
+			break
+		}
+	}
+	if anchor < 0 || m.Cursor >= len(nodes) {
+		// 没有起点时等同于切换当前节点
+		return m.toggleSelect()
+	}
+
+	from, to := anchor, m.Cursor
+	if from > to {
+		from, to = to, from
+	}
+	if m.Selected == nil {
+		m.Selected = make(map[*model.Node]bool)
+	}
+	for _, node := range nodes[from : to+1] {
+		m.Selected[node] = true
+	}
+	m.StatusMsg = fmt.Sprintf("Selected %d items", to-from+1)
+	return m
+}
+
+// selectMatching 选中当前过滤条件 (搜索、内容搜索、Git 过滤) 命中的所有节点
+// 没有过滤条件时选中所有可见节点
+func (m MainModel) selectMatching() MainModel {
+	if m.Selected == nil {
+		m.Selected = make(map[*model.Node]bool)
+	}
+
+	count := 0
+	for _, node := range m.visibleNodes() {
+		if !m.matchesFilter(node) {
+			continue
+		}
+		m.Selected[node] = true
+		count++
+	}
+	m.StatusMsg = fmt.Sprintf("Selected %d matching items", count)
+	return m
+}
+
+// matchesFilter 判断节点自身 (而不是子孙) 是否命中当前的过滤条件
+func (m MainModel) matchesFilter(node *model.Node) bool {
+	if m.SearchInput.Value() != "" {
+		if s := m.searchResults(); s.err == nil {
+			if _, ok := s.matches[node]; !ok {
+				return false
+			}
+		}
+	}
+	if m.Grep != nil {
+		if _, ok := m.Grep.files[node]; !ok {
+			return false
+		}
+	}
+	if m.GitMode && (node.GitStatus == "" || node.GitStatus == model.GitIgnored) {
+		return false
+	}
+	return true
+}
+
+// clearSelection 清空选择
+func (m MainModel) clearSelection() MainModel {
+	m.Selected = nil
+	m.selectAnchor = nil
+	return m
+}
+
+// bulkToggleHidden 切换所有选中节点的隐藏状态：有未隐藏的就全部隐藏，否则全部取消隐藏
+func (m MainModel) bulkToggleHidden() MainModel {
+	nodes := m.selectedNodes()
+	hide := false
+	for _, node := range nodes {
+		if !node.Hidden {
+			hide = true
+			break
+		}
+	}
+	for _, node := range nodes {
+		node.Hidden = hide
+	}
+	if hide {
+		m.StatusMsg = fmt.Sprintf("Hid %d items", len(nodes))
+	} else {
+		m.StatusMsg = fmt.Sprintf("Unhid %d items", len(nodes))
+	}
+	return m
+}
+
+// bulkToggleCollapsed 切换所有选中文件夹的折叠状态：有展开的就全部折叠，否则全部展开
+func (m MainModel) bulkToggleCollapsed() MainModel {
+	var dirs []*model.Node
+	collapse := false
+	for _, node := range m.selectedNodes() {
+		if !node.IsDir {
+			continue
+		}
+		dirs = append(dirs, node)
+		if !node.Collapsed {
+			collapse = true
+		}
+	}
+	for _, node := range dirs {
+		node.Collapsed = collapse
+	}
+	m.clampCursor()
+	if collapse {
+		m.StatusMsg = fmt.Sprintf("Collapsed %d folders", len(dirs))
+	} else {
+		m.StatusMsg = fmt.Sprintf("Expanded %d folders", len(dirs))
+	}
+	return m
+}
+
+// commonAnnotation 返回选中节点共同的注释，注释不一致时返回空
+func (m MainModel) commonAnnotation() string {
+	nodes := m.selectedNodes()
+	if len(nodes) == 0 {
+		return ""
+	}
+	annotation := nodes[0].Annotation
+	for _, node := range nodes[1:] {
+		if node.Annotation != annotation {
+			return ""
+		}
+	}
+	return annotation
+}
+
+// copySelectedPaths 把选中节点的相对路径 (每行一个) 复制到剪贴板
+func (m MainModel) copySelectedPaths() MainModel {
+	nodes := m.selectedNodes()
+	if len(nodes) == 0 {
+		idx := 0
+		if node := m.getNodeAtCursor(m.RootNode.Children, &idx); node != nil {
+			nodes = []*model.Node{node}
+		}
+	}
+	if len(nodes) == 0 {
+		return m
+	}
+
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		paths[i] = core.NodeRelPath(m.RootNode, node)
+	}
+	if err := clipboard.WriteAll(strings.Join(paths, "\n")); err != nil {
+		m.StatusMsg = "Error copying to clipboard!"
+	} else {
+		m.StatusMsg = fmt.Sprintf("Copied %d paths to clipboard", len(paths))
+	}
+	return m
+}
+
+// clampCursor 在可见节点减少后把光标限制在范围内
+func (m *MainModel) clampCursor() {
+	if total := m.countVisibleNodes(m.RootNode.Children); m.Cursor >= total {
+		m.Cursor = max(total-1, 0)
+	}
+	if m.ScrollOffset > m.Cursor {
+		m.ScrollOffset = m.Cursor
+	}
+}